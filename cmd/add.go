@@ -34,5 +34,16 @@ func (self *Add) Run(ctx *gpm.Ctx) {
 		ctx.Die("%+v", err)
 	}
 
-	ctx.Get(dep.Remote(), dep.Version)
+	lock, err := ctx.GetDependency(dep, dep.Version)
+	if err != nil {
+		ctx.Die("add repo fail:%+v", err)
+	}
+
+	ctx.AddDependency(dep)
+	if err := ctx.Save(); err != nil {
+		ctx.Die("save config fail:%+v", err)
+	}
+
+	ctx.LockFile.Set(lock)
+	ctx.SaveLock()
 }
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"github.com/Masterminds/semver"
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Audit 检查依赖的漏洞
+type Audit struct {
+}
+
+func (self *Audit) Cmd() cli.Command {
+	return cli.Command{
+		Name:  "audit",
+		Usage: "Check locked dependencies against OSV advisories",
+		Description: `Matches every locked dependency against a local OSV database (a dir or zip
+		of json files), by package path and semver or commit ranges. No network is used.
+		Exits non-zero when an advisory at or above the severity is found.
+		Advisories without severity are ranked as unknown, default is high.
+		Archive dependencies are matched by the version in the archive file name.
+
+		    audit:
+		      db: ~/osv/go.zip
+		      severity: high
+		      unknown: medium
+
+		With --fix the constraints in gpm.yaml are changed to the smallest fixed
+		version, run 'gpm update' afterwards.`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "db",
+				Usage: "dir or zip of OSV advisories",
+			},
+			cli.StringFlag{
+				Name:  "severity",
+				Usage: "fail at or above level: low, medium, high, critical",
+			},
+			cli.StringFlag{
+				Name:  "unknown",
+				Usage: "level of advisories without severity, default is high",
+			},
+			cli.BoolFlag{
+				Name:  "fix",
+				Usage: "change constraints in gpm.yaml to fixed versions",
+			},
+		},
+	}
+}
+
+// Run audit all locked deps
+func (self *Audit) Run(ctx *gpm.Ctx) {
+	ctx.Offline = true
+	ctx.MustLoad()
+
+	cfg := &gpm.AuditConfig{Severity: "low", Unknown: "high"}
+	if ctx.Audit != nil {
+		if ctx.Audit.DB != "" {
+			cfg.DB = ctx.Audit.DB
+		}
+		if ctx.Audit.Severity != "" {
+			cfg.Severity = ctx.Audit.Severity
+		}
+		if ctx.Audit.Unknown != "" {
+			cfg.Unknown = ctx.Audit.Unknown
+		}
+	}
+
+	if ctx.String("db") != "" {
+		cfg.DB = ctx.ArgPath(ctx.String("db"))
+	}
+
+	if ctx.String("severity") != "" {
+		cfg.Severity = ctx.String("severity")
+	}
+
+	if ctx.String("unknown") != "" {
+		cfg.Unknown = ctx.String("unknown")
+	}
+
+	if cfg.DB == "" {
+		ctx.Die("no advisory db, use --db or audit.db in %s", gpm.ConfName)
+	}
+
+	threshold := gpm.SeverityRank(cfg.Severity)
+	if threshold == 0 {
+		ctx.Die("invalid severity:%+v", cfg.Severity)
+	}
+
+	unknown := gpm.SeverityRank(cfg.Unknown)
+	if unknown == 0 {
+		ctx.Die("invalid unknown severity:%+v", cfg.Unknown)
+	}
+
+	advisories, err := gpm.LoadAdvisories(gpm.ExpandHome(cfg.DB))
+	if err != nil {
+		ctx.Die("load advisories fail:%+v", err)
+	}
+
+	findings := ctx.MatchAdvisories(advisories)
+	failed := 0
+	fixes := make(map[string]string)
+	for _, f := range findings {
+		level := f.Advisory.Severity()
+		fixed := f.Fixed
+		if fixed == "" {
+			fixed = "-"
+		}
+
+		rank := gpm.SeverityRank(level)
+		if rank == 0 {
+			rank = unknown
+		}
+
+		if rank >= threshold {
+			failed++
+			ctx.Error("%s@%s\t%s\t%s\tfixed:%s\t%s", f.Name, f.Version, f.Advisory.ID, level, fixed, f.Advisory.Summary)
+		} else {
+			ctx.Warn("%s@%s\t%s\t%s\tfixed:%s\t%s", f.Name, f.Version, f.Advisory.ID, level, fixed, f.Advisory.Summary)
+		}
+
+		// the smallest version which fixes all advisories
+		if f.Fixed != "" && greaterVersion(f.Fixed, fixes[f.Name]) {
+			fixes[f.Name] = f.Fixed
+		}
+	}
+
+	changed := false
+	for _, dep := range ctx.Imports {
+		fixed, ok := fixes[dep.Name]
+		if !ok {
+			continue
+		}
+
+		constraint := gpm.FixConstraint(dep.Version, fixed)
+		switch {
+		case constraint == "":
+			ctx.Info("fix %s: constraint %s allows %s, run gpm update", dep.Name, dep.Version, fixed)
+		case ctx.Bool("fix"):
+			ctx.Info("fix %s: %s -> %s", dep.Name, dep.Version, constraint)
+			dep.Version = constraint
+			changed = true
+		default:
+			ctx.Info("suggest %s: %s -> %s", dep.Name, dep.Version, constraint)
+		}
+	}
+
+	if changed {
+		if err := ctx.Save(); err != nil {
+			ctx.Die("save config fail:%+v", err)
+		}
+		ctx.Info("run gpm update to install fixed versions")
+	}
+
+	ctx.Info("%d advisories found, %d at or above %s", len(findings), failed, cfg.Severity)
+	if failed > 0 {
+		ctx.Exit(1, "audit fail")
+	}
+}
+
+// 比较版本号,b为空时返回true
+func greaterVersion(a string, b string) bool {
+	if b == "" {
+		return true
+	}
+
+	va, err1 := semver.NewVersion(a)
+	vb, err2 := semver.NewVersion(b)
+	return err1 == nil && err2 == nil && va.GreaterThan(vb)
+}
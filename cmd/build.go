@@ -3,8 +3,8 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"text/tabwriter"
 
 	"github.com/codegangsta/cli"
 	"github.com/jeckbjy/gpm/gpm"
@@ -16,93 +16,157 @@ type Build struct {
 
 func (self *Build) Cmd() cli.Command {
 	return cli.Command{
-		Name:        "build",
-		Usage:       "",
-		Description: "",
+		Name:      "build",
+		Usage:     "Build targets of gpm.yaml into dist/",
+		ArgsUsage: "[--all-platforms] [target]",
+		Description: `Builds every target, or the given one, defined in gpm.yaml:
+
+		    build:
+		    - name: server
+		      main: cmd/server
+		      output: svc
+		      tags: [netgo]
+		      ldflags: -s -w
+		      cgo: false
+		      platforms: [linux/amd64, linux/arm64, darwin/amd64, windows/amd64]
+
+		Artifacts are written to dist/<target>/<goos>_<goarch>/<output>, jobs run in
+		parallel (--jobs). Only the host platform is built unless --all-platforms
+		is given. Without build section the project root is built as one target.
+
+		go runs with an allowlist of the environment (PATH, HOME, GOCACHE, proxies,
+		...) and env: of gpm.yaml. Vendored projects are built with -mod=vendor,
+		projects without go.mod in GOPATH mode, in the GOPATH they are in as
+		<gopath>/src/<package>, or in a private one, .gpm/gopath/src/<package>
+		linked to the project. Output is
+		streamed, the exit status of the first failed go build is returned.
+
+		Version info is injected into variables by -ldflags -X, or written to a
+		generated file if file is given. Time is SOURCE_DATE_EPOCH if set:
+
+		    stamp:
+		      version: main.Version
+		      commit: main.Commit
+		      dirty: main.Dirty
+		      time: main.BuildTime
+		      lock: main.LockHash
+
+		go: of gpm.yaml is a constraint of go version, eg: ">=1.10 <1.12". If go in
+		PATH does not match, the newest matched sdk of go-roots setting is used.
+
+		go build is skipped if sources (vendor excluded), gpm.lock, settings of the
+		target and go version are not changed since an artifact was built, the
+		artifact is copied from ~/.gpm/build instead. --force always builds,
+		--explain prints what changed.`,
 		Flags: []cli.Flag{
 			cli.StringFlag{
 				Name:  "name, n",
-				Usage: "build target name",
+				Usage: "output name if gpm.yaml has no build section",
 			},
 			cli.StringFlag{
 				Name:  "gopath, g",
-				Usage: "gopath for build",
+				Usage: "gopath for build, default is the private .gpm/gopath if project is not in a GOPATH",
+			},
+			cli.BoolFlag{
+				Name:  "all-platforms",
+				Usage: "build every platform of the targets",
+			},
+			cli.BoolFlag{
+				Name:  "force, f",
+				Usage: "build even if the artifact is cached",
+			},
+			cli.BoolFlag{
+				Name:  "explain",
+				Usage: "print why each artifact is rebuilt",
 			},
 		},
 	}
 }
 
-// Run build go code to bin
-// build -n xxxxx
+// Run build targets to dist
+// build [--all-platforms] [target]
 func (self *Build) Run(ctx *gpm.Ctx) {
+	if len(ctx.Args()) > 1 {
+		ctx.Die("build need at most one target")
+	}
+
 	ctx.MustLoad()
 
-	// get target name
-	name := ctx.String("name")
-	if name == "" {
-		name = ctx.Name
-		if name == "" || name == "." {
-			dir, err := os.Getwd()
-			if err == nil {
-				name = filepath.Base(dir)
-			}
-		}
+	targets := ctx.Targets
+	if len(targets) == 0 {
+		targets = []*gpm.Target{self.defaultTarget(ctx)}
+	}
 
-		if name == "" {
-			name = "server"
+	if len(ctx.Args()) == 1 {
+		name := ctx.Args()[0]
+		target := ctx.FindTarget(name)
+		if target == nil {
+			ctx.Die("cannot find build target:%+v", name)
 		}
+		targets = []*gpm.Target{target}
 	}
 
-	// get GOPATH,USE system gopath?
-	gopath := ctx.String("gopath")
-	if gopath == "" {
-		gopath = self.findGoPath()
+	opts := &gpm.BuildOptions{Force: ctx.Bool("force"), Explain: ctx.Bool("explain")}
+	self.build(ctx, targets, ctx.Bool("all-platforms"), ctx.String("gopath"), opts)
+}
+
+// build 编译并打印汇总,失败时以go build的退出码退出
+func (self *Build) build(ctx *gpm.Ctx, targets []*gpm.Target, allPlatforms bool, gopath string, opts *gpm.BuildOptions) []*gpm.Artifact {
+	// GOPATH is --gopath, the GOPATH project is in, or the private workspace
+	dir, env, err := ctx.GoEnv(gopath)
+	if err != nil {
+		ctx.Die("%+v", err)
 	}
 
-	if gopath == "" {
-		ctx.Die("cannot find gopath")
+	host := gpm.HostPlatform()
+	platforms := func(t *gpm.Target) []string {
+		if allPlatforms && len(t.Platforms) > 0 {
+			return t.Platforms
+		}
+		return []string{host}
 	}
 
-	// build
-	envGopath := fmt.Sprintf("GOPATH=%s", gopath)
-	target := filepath.Join(gopath, "bin", name)
-	ctx.Info("--> Build:%+v", name)
-	cmd := exec.Command("go", "build", "-o", target)
-
-	cmd.Env = append(cmd.Env, envGopath)
-	output, err := cmd.CombinedOutput()
-	if err == nil {
-		ctx.Info("    build ok, output is %+v", filepath.Join("bin", name))
-	} else {
-		ctx.Info("    build fail, %s", output)
+	artifacts := ctx.BuildArtifacts(targets, platforms, dir, env, opts)
+
+	// summary
+	failed := []*gpm.Artifact{}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tPLATFORM\tSTATUS\tTIME\tARTIFACT")
+	for _, a := range artifacts {
+		status := "ok"
+		if a.Cached {
+			status = "cached"
+		}
+
+		if a.Err != nil {
+			status = "FAIL"
+			failed = append(failed, a)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.1fs\t%s\n", a.Target.Name, a.Platform, status, a.Duration.Seconds(), a.Path)
 	}
-}
+	w.Flush()
 
-// 查找gopath,三级目录内包含bin或者src
-func (self *Build) findGoPath() string {
-	wd, err := os.Getwd()
-	if err != nil {
-		return ""
+	for _, a := range failed {
+		ctx.Error("%s %s: %+v", a.Target.Name, a.Platform, a.Err)
 	}
 
-	// 当前目录含有src目录
-	if fi, err := os.Stat("./src"); err == nil && fi.IsDir() {
-		// ctx.Debug("find gopath:%+v", wd)
-		return wd
+	// exit status of the first failed go build
+	if len(failed) > 0 {
+		ctx.Exit(failed[0].ExitCode, "%d of %d builds failed", len(failed), len(artifacts))
 	}
 
-	// 从当前目录向上查找src,bin,pkg
-	// src/github.com/user/project
-	dir := wd
-	for i := 0; i < 3; i++ {
-		node := filepath.Base(dir)
-		if node == "src" || node == "bin" {
-			// 上级目录则为gopath
-			return filepath.Dir(dir)
-		}
+	return artifacts
+}
 
-		dir = filepath.Dir(dir)
+// defaultTarget 没有配置build时编译项目根目录
+func (self *Build) defaultTarget(ctx *gpm.Ctx) *gpm.Target {
+	name := ctx.String("name")
+	if name == "" {
+		name = filepath.Base(ctx.Name)
+		if name == "" || name == "." {
+			name = filepath.Base(ctx.WorkDir)
+		}
 	}
 
-	return ""
+	return &gpm.Target{Name: name}
 }
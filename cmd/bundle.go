@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Bundle 打包和导入离线依赖
+type Bundle struct {
+}
+
+func (self *Bundle) Cmd() cli.Command {
+	return cli.Command{
+		Name:      "bundle",
+		Usage:     "Pack locked dependencies for offline hosts",
+		ArgsUsage: "[--cache dir] export|import <file>",
+		Description: `'gpm bundle export <file>' packs the cache of every locked dependency
+		into a tar.gz with a manifest and checksums.
+
+		'gpm bundle import <file>' seeds the cache from the bundle, after which
+		'gpm install --offline' succeeds without network. The bundle must be
+		created from the same gpm.lock.`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "cache",
+				Usage: "cache dir to import into, default is ~/.gpm",
+			},
+		},
+	}
+}
+
+// Run dispatch bundle sub command
+func (self *Bundle) Run(ctx *gpm.Ctx) {
+	args := ctx.Args()
+	if len(args) != 2 {
+		cli.ShowCommandHelp(ctx.Context, ctx.Command.Name)
+		return
+	}
+
+	ctx.MustLoad()
+
+	switch args[0] {
+	case "export":
+		if err := ctx.ExportBundle(ctx.ArgPath(args[1])); err != nil {
+			ctx.Die("export bundle fail:%+v", err)
+		}
+		ctx.Info("export bundle to %s", args[1])
+	case "import":
+		cacheDir := ctx.ArgPath(ctx.String("cache"))
+		if cacheDir == "" {
+			cacheDir = ctx.CacheDir
+		}
+
+		if err := ctx.ImportBundle(ctx.ArgPath(args[1]), cacheDir); err != nil {
+			ctx.Die("import bundle fail:%+v", err)
+		}
+		ctx.Info("import bundle to %s", cacheDir)
+	default:
+		cli.ShowCommandHelp(ctx.Context, ctx.Command.Name)
+	}
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Config 查看和修改设置
+type Config struct {
+}
+
+func (self *Config) Cmd() cli.Command {
+	return cli.Command{
+		Name:      "config",
+		Usage:     "Get and set user, system or project settings, or print resolved gpm.yaml",
+		ArgsUsage: "[--show-origin] [--system|--project] list|get|set|unset|resolved [key] [value]",
+		Description: `Settings are merged in order: system /etc/gpm/config.yaml, user
+		~/.gpm/config.yaml, project gpm.yaml (settings:), environment variables
+		(GPM_CACHE, GPM_JOBS, ...), then global flags (--cache-dir, --jobs, --color).
+
+		Keys: cache, trusted-keys, credential-helper, proxy, jobs, color, go-roots,
+		mirrors.<package>
+
+		    gpm config --show-origin list
+		    gpm config set mirrors.github.com/org https://git.corp/mirror/org
+		    gpm config --project set jobs 4
+		    gpm config set go-roots "~/sdk/go*,/usr/local/go"
+
+		set and unset change the user settings unless --system or --project is given.
+		trusted-keys, credential-helper and go-roots are only accepted from system
+		and user settings, a project or its extends cannot set them.
+
+		resolved prints gpm.yaml merged with its extends, inherited imports are
+		commented with the base they came from.`,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "show-origin",
+				Usage: "show file, env or flag of each value",
+			},
+			cli.BoolFlag{
+				Name:  "system",
+				Usage: "set or unset in " + gpm.SystemSettings,
+			},
+			cli.BoolFlag{
+				Name:  "project",
+				Usage: "set or unset in " + gpm.ConfName,
+			},
+		},
+	}
+}
+
+// Run dispatch config sub command
+func (self *Config) Run(ctx *gpm.Ctx) {
+	args := ctx.Args()
+	if len(args) == 0 {
+		cli.ShowCommandHelp(ctx.Context, ctx.Command.Name)
+		return
+	}
+
+	// settings work outside of project too
+	if ctx.AutoChangeDir() {
+		ctx.MustLoad()
+	}
+
+	switch {
+	case args[0] == "resolved" && len(args) == 1:
+		if !ctx.Exist() {
+			ctx.Die("not find config,use gpm init to create")
+		}
+
+		data, err := ctx.ResolvedYAML()
+		if err != nil {
+			ctx.Die("%+v", err)
+		}
+		ctx.Print(string(data))
+	case args[0] == "list" && len(args) == 1:
+		for _, key := range ctx.Settings.Keys() {
+			value, _ := ctx.Settings.Get(key)
+			self.print(ctx, key, value)
+		}
+	case args[0] == "get" && len(args) == 2:
+		value, ok := ctx.Settings.Get(args[1])
+		if !ok {
+			ctx.Exit(1, "not set:%+v", args[1])
+		}
+		self.print(ctx, args[1], value)
+	case args[0] == "set" && len(args) == 3:
+		if ctx.Bool("project") && gpm.IsMachineKey(args[1]) {
+			ctx.Die("%s is only allowed in system or user settings", args[1])
+		}
+
+		self.edit(ctx, func(s *gpm.Settings) error {
+			return s.Set(args[1], args[2])
+		})
+	case args[0] == "unset" && len(args) == 2:
+		self.edit(ctx, func(s *gpm.Settings) error {
+			if !s.Unset(args[1]) {
+				ctx.Die("not set:%+v", args[1])
+			}
+			return nil
+		})
+	default:
+		cli.ShowCommandHelp(ctx.Context, ctx.Command.Name)
+	}
+}
+
+func (self *Config) print(ctx *gpm.Ctx, key string, value string) {
+	if ctx.Bool("show-origin") {
+		ctx.Puts("%s\t%s=%s", ctx.Settings.Origin(key), key, value)
+	} else {
+		ctx.Puts("%s=%s", key, value)
+	}
+}
+
+// edit 修改某一层的设置并保存
+func (self *Config) edit(ctx *gpm.Ctx, fn func(s *gpm.Settings) error) {
+	if ctx.Bool("project") {
+		if !ctx.Exist() {
+			ctx.Die("not find config,use gpm init to create")
+		}
+
+		// inherited settings are not written to gpm.yaml
+		if err := fn(ctx.LocalSettings()); err != nil {
+			ctx.Die("%+v", err)
+		}
+
+		if err := ctx.Save(); err != nil {
+			ctx.Die("save config fail:%+v", err)
+		}
+		return
+	}
+
+	file := gpm.UserSettings()
+	if ctx.Bool("system") {
+		file = gpm.SystemSettings
+	}
+
+	s, err := gpm.LoadSettingsFile(file)
+	if err != nil {
+		ctx.Die("%+v", err)
+	}
+
+	if s == nil {
+		s = &gpm.Settings{}
+	}
+
+	if err := fn(s); err != nil {
+		ctx.Die("%+v", err)
+	}
+
+	if err := s.SaveFile(file); err != nil {
+		ctx.Die("save %s fail:%+v", file, err)
+	}
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Exec 在项目的GOPATH和vendor环境中执行命令
+type Exec struct {
+}
+
+func (self *Exec) Cmd() cli.Command {
+	return cli.Command{
+		Name:      "exec",
+		Usage:     "Run a command with GOPATH and vendor of the project",
+		ArgsUsage: "-- <cmd> [args...]",
+		Description: `Runs any command (go vet, dlv, custom tools) in the environment used by
+		gpm build: GOPATH is the one project is in, or the private .gpm/gopath,
+		vendor is in effect and env: of gpm.yaml is set.
+
+		    gpm exec -- go vet ./...
+		    gpm exec -- dlv debug ./cmd/server
+
+		The exit status of the command is returned.`,
+		SkipFlagParsing: true,
+	}
+}
+
+// Run 执行命令并返回其退出码
+func (self *Exec) Run(ctx *gpm.Ctx) {
+	args := []string(ctx.Args())
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		ctx.Die("exec need a command")
+	}
+
+	ctx.MustLoad()
+
+	dir, env, err := ctx.GoEnv("")
+	if err != nil {
+		ctx.Die("%+v", err)
+	}
+
+	// run in the same sub dir of project as gpm
+	if rel, err := filepath.Rel(ctx.WorkDir, ctx.StartDir); err == nil && !strings.HasPrefix(rel, "..") {
+		dir = filepath.Join(dir, rel)
+	}
+	env = append(env, "PWD="+dir)
+
+	// other tools need the whole environment
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Env = gpm.MergeEnv(os.Environ(), env)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		if ee, ok := err.(*exec.ExitError); ok {
+			os.Exit(ee.ExitCode())
+		}
+		ctx.Die("%+v", err)
+	}
+}
@@ -14,6 +14,12 @@ func (self *Install) Cmd() cli.Command {
 		ShortName:   "i",
 		Usage:       "Install a project's dependencies",
 		Description: "",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "offline",
+				Usage: "install from cache only, never fetch from network",
+			},
+		},
 	}
 }
 
@@ -23,12 +29,27 @@ func (self *Install) Run(ctx *gpm.Ctx) {
 		ctx.Die("install donot need args")
 	}
 
+	ctx.Offline = ctx.Bool("offline")
+	ctx.FetchBases = true
 	ctx.MustLoad()
+	self.install(ctx)
+}
 
+// install 下载所有依赖并更新lock
+func (self *Install) install(ctx *gpm.Ctx) {
 	// get all
 	for _, dep := range ctx.Imports {
-		if err := ctx.Get(dep.Remote(), dep.Version); err != nil {
+		lock, err := ctx.GetDependency(dep, ctx.LockedVersion(dep))
+		if err != nil {
 			ctx.Die("%+v", err)
 		}
+
+		ctx.LockFile.Set(lock)
+	}
+
+	ctx.SaveLock()
+
+	if err := ctx.CheckLicenses(); err != nil {
+		ctx.Die("%+v", err)
 	}
 }
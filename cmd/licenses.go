@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Licenses 检测依赖的license
+type Licenses struct {
+}
+
+func (self *Licenses) Cmd() cli.Command {
+	return cli.Command{
+		Name:  "licenses",
+		Usage: "Report licenses of vendored dependencies",
+		Description: `Scans LICENSE/COPYING/NOTICE files of every vendored package and
+		classifies them by SPDX identifier. A policy in gpm.yaml makes install,
+		update and --check fail on disallowed or unknown licenses:
+
+		    licenses:
+		      allow: [MIT, Apache-2.0, BSD-3-Clause]
+		      deny: [GPL-3.0, AGPL-3.0]
+		      ignore: [example.com/internal/lib]`,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "check",
+				Usage: "fail if any license violates the policy",
+			},
+			cli.BoolFlag{
+				Name:  "notices",
+				Usage: "write combined licenses and notices to " + gpm.NoticeName,
+			},
+		},
+	}
+}
+
+// Run print license report
+func (self *Licenses) Run(ctx *gpm.Ctx) {
+	ctx.MustLoad()
+
+	for _, report := range ctx.LicenseReports() {
+		license := report.License
+		if license == "" {
+			license = "-"
+		}
+
+		files := []string{}
+		for _, f := range report.Files {
+			files = append(files, f.Path)
+		}
+		files = append(files, report.Notices...)
+
+		line := report.Name + "\t" + license + "\t" + strings.Join(files, ",")
+		if report.Violation != "" {
+			ctx.Warn("%s\t%s", line, report.Violation)
+		} else {
+			ctx.Puts("%s", line)
+		}
+	}
+
+	if ctx.Bool("notices") {
+		if err := ctx.WriteNotices(gpm.NoticeName); err != nil {
+			ctx.Die("write notices fail:%+v", err)
+		}
+		ctx.Info("write %s", gpm.NoticeName)
+	}
+
+	if ctx.Bool("check") {
+		if ctx.Licenses == nil {
+			ctx.Warn("no license policy in %s", gpm.ConfName)
+		}
+
+		if err := ctx.CheckLicenses(); err != nil {
+			ctx.Die("%+v", err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Lint 严格检查配置文件
+type Lint struct {
+}
+
+func (self *Lint) Cmd() cli.Command {
+	return cli.Command{
+		Name:  "lint",
+		Usage: "Check gpm.yaml strictly and report problems with line and column",
+		Description: `Reports unknown keys, duplicate packages, invalid semver constraints,
+		malformed repo urls and invalid owner emails. Errors are also checked
+		by every command which loads gpm.yaml.
+
+		With --schema the JSON Schema of gpm.yaml is printed instead, editors
+		can use it for validation and completion.`,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "schema",
+				Usage: "print JSON Schema of gpm.yaml",
+			},
+		},
+	}
+}
+
+// Run lint gpm.yaml
+func (self *Lint) Run(ctx *gpm.Ctx) {
+	if ctx.Bool("schema") {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(gpm.JSONSchema()); err != nil {
+			ctx.Die("%+v", err)
+		}
+		return
+	}
+
+	if !ctx.AutoChangeDir() {
+		ctx.Die("not find config,use gpm init to create")
+	}
+
+	issues, err := gpm.Lint(gpm.ConfName)
+	if err != nil {
+		ctx.Die("%+v", err)
+	}
+
+	failed := 0
+	for _, issue := range issues {
+		ctx.Puts("%s", issue)
+		if !issue.Warning {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		ctx.Exit(1, "%d errors in %s", failed, gpm.ConfName)
+	}
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Patch 管理vendor中依赖的补丁
+type Patch struct {
+}
+
+func (self *Patch) Cmd() cli.Command {
+	return cli.Command{
+		Name:      "patch",
+		Usage:     "Manage patches of vendored dependencies",
+		ArgsUsage: "create <package>",
+		Description: `Patches listed in gpm.yaml are applied in order after every export:
+
+		    import:
+		    - package: github.com/user/lib
+		      patches:
+		      - patches/github.com-user-lib-0.patch
+
+		'gpm patch create <package>' diffs vendor/<package> against the pristine
+		export of the locked version (with existing patches applied), writes the
+		diff to the patches directory and appends it to gpm.yaml.`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "output, o",
+				Usage: "patch file to write",
+			},
+		},
+	}
+}
+
+// Run dispatch patch sub command
+func (self *Patch) Run(ctx *gpm.Ctx) {
+	args := ctx.Args()
+	if len(args) != 2 || args[0] != "create" {
+		cli.ShowCommandHelp(ctx.Context, ctx.Command.Name)
+		return
+	}
+
+	ctx.MustLoad()
+	self.create(ctx, args[1])
+}
+
+// 对比vendor和原始导出,生成补丁
+func (self *Patch) create(ctx *gpm.Ctx, name string) {
+	dep := ctx.FindDependency(name)
+	if dep == nil {
+		ctx.Die("cannot find dependency:%+v", name)
+	}
+
+	if r := ctx.FindReplace(name); r != nil && r.IsLocal() {
+		ctx.Die("cannot patch local replace:%+v", name)
+	}
+
+	lock := ctx.LockFile.Find(name)
+	if lock == nil || lock.Reversion == "" {
+		ctx.Die("dependency not locked, run gpm install first:%+v", name)
+	}
+
+	tmp, err := gpm.TempDir("patch")
+	if err != nil {
+		ctx.Die("%+v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	pristine := filepath.Join(tmp, "pristine")
+	if err := ctx.ExportPristine(dep, lock.Reversion, pristine); err != nil {
+		ctx.Die("export %s fail:%+v", name, err)
+	}
+
+	if err := gpm.ApplyPatches(pristine, dep.Patches); err != nil {
+		ctx.Die("%+v", err)
+	}
+
+	diff, err := gpm.DiffDir(pristine, filepath.Join("vendor", name))
+	if err != nil {
+		ctx.Die("%+v", err)
+	}
+
+	if len(diff) == 0 {
+		ctx.Die("no changes in vendor/%s", name)
+	}
+
+	output := ctx.String("output")
+	if output != "" {
+		output = ctx.RelPath(output)
+	} else {
+		base := strings.Replace(name, "/", "-", -1)
+		output = filepath.ToSlash(filepath.Join("patches", base+"-"+strconv.Itoa(len(dep.Patches))+".patch"))
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		ctx.Die("%+v", err)
+	}
+
+	if err := ioutil.WriteFile(output, diff, 0666); err != nil {
+		ctx.Die("write patch fail:%+v", err)
+	}
+
+	dep.Patches = append(dep.Patches, output)
+	if err := ctx.Save(); err != nil {
+		ctx.Die("save config fail:%+v", err)
+	}
+
+	// vendor dir is the patched result now
+	if lock.Hash, err = gpm.HashDir(filepath.Join("vendor", name)); err == nil {
+		ctx.SaveLock()
+	}
+
+	ctx.Info("create patch %s for %s", output, name)
+}
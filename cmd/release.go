@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Release 编译所有平台并打包,生成校验和与tag
+type Release struct {
+}
+
+func (self *Release) Cmd() cli.Command {
+	return cli.Command{
+		Name:  "release",
+		Usage: "Build every platform, package artifacts and tag the version",
+		Description: `Runs the whole build matrix of gpm.yaml, then packages each artifact with
+		README, LICENSE and THIRD_PARTY_NOTICES into
+
+		    dist/release/<version>/<target>_<version>_<goos>_<goarch>.tar.gz (.zip for windows)
+		    dist/release/<version>/SHA256SUMS
+
+		--bump increases version of gpm.yaml and commits it, then annotated tag
+		v<version> is created. If build or package fails the version commit is
+		reset. Everything is local, push the tag yourself:
+
+		    gpm release --bump minor
+		    git push origin v1.3.0
+
+		Packages are reproducible if SOURCE_DATE_EPOCH is set.`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "bump",
+				Usage: "increase version: major, minor, patch or prerelease",
+			},
+			cli.StringFlag{
+				Name:  "pre",
+				Value: "rc",
+				Usage: "identifier of prerelease, eg: rc for 1.2.0-rc.1",
+			},
+			cli.BoolFlag{
+				Name:  "no-tag",
+				Usage: "do not commit or tag",
+			},
+			cli.StringFlag{
+				Name:  "gopath, g",
+				Usage: "gopath for build",
+			},
+		},
+	}
+}
+
+// Run release
+func (self *Release) Run(ctx *gpm.Ctx) {
+	if len(ctx.Args()) != 0 {
+		ctx.Die("release donot need args")
+	}
+
+	ctx.MustLoad()
+
+	tagging := !ctx.Bool("no-tag")
+	if tagging {
+		if err := gpm.GitClean(); err != nil {
+			ctx.Die("%+v", err)
+		}
+	}
+
+	// step1: bump version
+	version := ctx.Config.Version
+	if bump := ctx.String("bump"); bump != "" {
+		next, err := gpm.BumpVersion(version, bump, ctx.String("pre"))
+		if err != nil {
+			ctx.Die("%+v", err)
+		}
+		version = next
+	}
+
+	tag := "v" + version
+	if tagging && gpm.GitTagExists(tag) {
+		ctx.Die("tag %s already exists, use --bump to release a new version", tag)
+	}
+
+	// committed before build so the stamped commit is the tagged one
+	if old := ctx.Config.Version; version != old {
+		ctx.Info("--> Version %s => %s", old, version)
+		ctx.Config.Version = version
+		if err := ctx.Save(); err != nil {
+			ctx.Die("save config fail:%+v", err)
+		}
+
+		committed := false
+		if tagging {
+			if err := gpm.CommitVersion(tag); err != nil {
+				ctx.Die("%+v", err)
+			}
+			committed = true
+		}
+
+		defer self.rollback(ctx, old, committed)()
+	}
+
+	// step2: build all platforms, exit if any fails
+	targets := ctx.Targets
+	if len(targets) == 0 {
+		targets = []*gpm.Target{(&Build{}).defaultTarget(ctx)}
+	}
+	artifacts := (&Build{}).build(ctx, targets, true, ctx.String("gopath"), &gpm.BuildOptions{})
+
+	// step3: package with docs and notices
+	tmp, err := gpm.TempDir("release")
+	if err != nil {
+		ctx.Die("%+v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	notices := filepath.Join(tmp, gpm.NoticeName)
+	if err := ctx.WriteNotices(notices); err != nil {
+		ctx.Die("write notices fail:%+v", err)
+	}
+
+	dir := filepath.Join(gpm.ReleaseDir, version)
+	files := append(gpm.ReleaseDocs(), notices)
+	packages := []string{}
+	for _, a := range artifacts {
+		file, err := ctx.PackageArtifact(a, version, dir, files)
+		if err != nil {
+			ctx.Die("package %s %s fail:%+v", a.Target.Name, a.Platform, err)
+		}
+
+		ctx.Info("--> Package %s", file)
+		packages = append(packages, file)
+	}
+
+	if err := gpm.WriteChecksums(dir, packages); err != nil {
+		ctx.Die("write checksums fail:%+v", err)
+	}
+	ctx.Info("--> Checksums %s", filepath.Join(dir, gpm.ChecksumsName))
+
+	// step4: tag
+	if tagging {
+		if err := gpm.TagRelease(tag); err != nil {
+			ctx.Die("%+v", err)
+		}
+		ctx.Info("--> Tag %s, push it by: git push origin %s", tag, tag)
+	}
+}
+
+// rollback traps failures of build, package and tag, the bumped version is
+// reset so that no release commit is left without its tag
+func (self *Release) rollback(ctx *gpm.Ctx, version string, committed bool) func() {
+	ctx.PanicOnDie = true
+	return func() {
+		ctx.PanicOnDie = false
+		r := recover()
+		if r == nil {
+			return
+		}
+
+		if r != "trapped" {
+			panic(r)
+		}
+
+		// exit status of the failed step, eg: go build
+		code := ctx.ExitCode
+
+		if committed {
+			if err := gpm.ResetVersion(); err != nil {
+				ctx.Die("reset release commit fail:%+v", err)
+			}
+		} else {
+			ctx.Config.Version = version
+			if err := ctx.Save(); err != nil {
+				ctx.Die("restore version fail:%+v", err)
+			}
+		}
+
+		ctx.Exit(code, "release fail, version is reset to %s", version)
+	}
+}
@@ -29,28 +29,37 @@ func (self *Remove) Run(ctx *gpm.Ctx) {
 	ctx.MustLoad()
 
 	name := ctx.Args()[0]
+	dep := ctx.FindDependency(name)
+	if dep != nil && dep.Base != "" {
+		ctx.Die("%s is inherited from %s, remove it there", name, dep.Base)
+	}
+
 	if !ctx.DelDependency(name) {
 		ctx.Die("cannot find dependency:%+v", name)
 	}
 
 	ctx.Info("remove deps: %+v", name)
 
-	// remove from cache
-	removeAll(ctx.CacheDir, name)
-	// cache := filepath.Join(ctx.CacheDir, name)
-	// os.RemoveAll(cache)
+	// remove from cache, cache dir is keyed by remote url
+	if dep.IsArchive() {
+		os.Remove(ctx.ArchivePath(dep))
+	} else if local, err := ctx.CacheLocal(ctx.RemoteOf(dep)); err == nil {
+		os.RemoveAll(local)
+	}
 
 	// remove from vendor
-	removeAll("/vendor", name)
-	// vendor := filepath.Join(ctx.VendorDir, name)
-	// os.RemoveAll(vendor)
+	removeAll(filepath.Join(ctx.WorkDir, "vendor"), name)
 
 	// TODO: remove sub dependency tree
-	// TODO: remove deps from lock file
+	if ctx.LockFile.Del(name) {
+		ctx.SaveLock()
+	}
 
 	// TODO: remove empty dir
 
-	ctx.Save()
+	if err := ctx.Save(); err != nil {
+		ctx.Die("save config fail:%+v", err)
+	}
 }
 
 // 递归向上删除所有空文件夹
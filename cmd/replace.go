@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Replace 将依赖替换为本地目录或者fork的repo
+type Replace struct {
+}
+
+func (self *Replace) Cmd() cli.Command {
+	return cli.Command{
+		Name:      "replace",
+		Usage:     "replace a package with a local directory or a fork repo",
+		ArgsUsage: "<package> <path-or-url>",
+		Description: `A local path is linked (or copied) to vendor/<package>,
+		a fork url is fetched through the cache like any other repo.
+
+		    gpm replace github.com/user/lib ../lib
+		    gpm replace github.com/user/lib https://github.com/me/lib
+		    gpm replace --drop github.com/user/lib`,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "drop, d",
+				Usage: "remove the replace of package",
+			},
+		},
+	}
+}
+
+// Run 添加或删除replace,并重新获取依赖
+func (self *Replace) Run(ctx *gpm.Ctx) {
+	args := ctx.Args()
+	// detect before changing to project root
+	local := len(args) == 2 && isLocalPath(args[1])
+
+	ctx.MustLoad()
+
+	if ctx.Bool("drop") {
+		if len(args) != 1 {
+			ctx.Die("replace --drop need one package!")
+		}
+
+		if !ctx.DelReplace(args[0]) {
+			ctx.Die("cannot find replace:%+v", args[0])
+		}
+	} else {
+		if len(args) != 2 {
+			ctx.Die("replace need package and path or url!")
+		}
+
+		replace := &gpm.Replace{Name: args[0]}
+		if local && strings.HasPrefix(args[1], "~") {
+			replace.Path = args[1]
+		} else if local {
+			replace.Path = ctx.RelPath(args[1])
+		} else {
+			replace.Repository = args[1]
+		}
+
+		ctx.SetReplace(replace)
+	}
+
+	if err := ctx.Save(); err != nil {
+		ctx.Die("save config fail:%+v", err)
+	}
+
+	// refetch if dependency is in gpm.yaml
+	for _, dep := range ctx.Imports {
+		if dep.Name != args[0] {
+			continue
+		}
+
+		lock, err := ctx.GetDependency(dep, dep.Version)
+		if err != nil {
+			ctx.Die("%+v", err)
+		}
+
+		ctx.LockFile.Set(lock)
+		ctx.SaveLock()
+	}
+}
+
+// 判断是否是本地路径,否则认为是repo地址
+func isLocalPath(path string) bool {
+	if strings.Contains(path, "://") || strings.HasPrefix(path, "git@") {
+		return false
+	}
+
+	if filepath.IsAbs(path) || strings.HasPrefix(path, ".") || strings.HasPrefix(path, "~") {
+		return true
+	}
+
+	fi, err := os.Stat(path)
+	return err == nil && fi.IsDir()
+}
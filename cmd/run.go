@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Run 执行gpm.yaml中scripts定义的命令
+type Run struct {
+}
+
+func (self *Run) Cmd() cli.Command {
+	return cli.Command{
+		Name:      "run",
+		Usage:     "Run a script of gpm.yaml",
+		ArgsUsage: "[<script> [-- args...]]",
+		Description: `Runs a script defined in gpm.yaml, scripts it depends on run first, each
+		once. Without script the scripts are listed with their descriptions.
+
+		    scripts:
+		      generate:
+		        description: generate code
+		        run: go generate ./...
+		      lint:
+		        description: run linters
+		        run: |
+		          go vet ./...
+		          golint "$@" ./...
+		        deps: [generate]
+
+		Scripts run by sh -e (cmd /C on windows) in the project root, with the
+		environment of gpm build: GOPATH, vendor and env: of gpm.yaml. Args after
+		-- are passed to the script as "$@", not to its deps.
+
+		    gpm run lint -- -set_exit_status
+
+		The exit status of the first failed script is returned.`,
+		SkipFlagParsing: true,
+	}
+}
+
+// Run 按依赖顺序执行脚本,失败时以脚本的退出码退出
+// run [<script> [-- args...]]
+func (self *Run) Run(ctx *gpm.Ctx) {
+	args := []string(ctx.Args())
+	ctx.MustLoad()
+
+	if len(args) == 0 {
+		self.list(ctx)
+		return
+	}
+
+	name, args := args[0], args[1:]
+	if len(args) > 0 && args[0] == "--" {
+		args = args[1:]
+	}
+
+	order, err := ctx.ScriptOrder(name)
+	if err != nil {
+		ctx.Die("%+v", err)
+	}
+
+	dir, env, err := ctx.GoEnv("")
+	if err != nil {
+		ctx.Die("%+v", err)
+	}
+	env = append(env, "PWD="+dir)
+
+	for _, script := range order {
+		s := ctx.Scripts[script]
+		if s.Run == "" {
+			continue
+		}
+
+		// args are for the given script only
+		var scriptArgs []string
+		if script == name {
+			scriptArgs = args
+		}
+
+		ctx.Info("--> Run %s", script)
+		cmd := s.Command(script, scriptArgs)
+		cmd.Dir = dir
+		cmd.Env = gpm.MergeEnv(os.Environ(), env)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			if ee, ok := err.(*exec.ExitError); ok {
+				ctx.Exit(ee.ExitCode(), "script %s fail:%+v", script, err)
+			}
+			ctx.Die("script %s fail:%+v", script, err)
+		}
+	}
+}
+
+// list 列出所有脚本及其说明
+func (self *Run) list(ctx *gpm.Ctx) {
+	names := ctx.ScriptNames()
+	if len(names) == 0 {
+		ctx.Info("no scripts in %s", gpm.ConfName)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "SCRIPT\tDEPS\tDESCRIPTION")
+	for _, name := range names {
+		s := ctx.Scripts[name]
+		deps := "-"
+		if len(s.Deps) > 0 {
+			deps = strings.Join(s.Deps, ",")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, deps, s.Desc)
+	}
+	w.Flush()
+}
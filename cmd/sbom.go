@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Sbom 导出软件物料清单
+type Sbom struct {
+}
+
+func (self *Sbom) Cmd() cli.Command {
+	return cli.Command{
+		Name:  "sbom",
+		Usage: "Export software bill of materials from gpm.lock and vendor/",
+		Description: `Lists every locked dependency with package url, resolved revision,
+		content hash, detected license and dependency relationships.
+		Nothing is fetched, the sbom is built from gpm.lock and vendor/ only.`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "format, f",
+				Value: gpm.SbomCycloneDX,
+				Usage: "cyclonedx-json, spdx-json or spdx-tag",
+			},
+			cli.StringFlag{
+				Name:  "output, o",
+				Usage: "output file, default is stdout",
+			},
+		},
+	}
+}
+
+// Run write sbom
+func (self *Sbom) Run(ctx *gpm.Ctx) {
+	ctx.MustLoad()
+
+	sbom, err := ctx.NewSbom(ctx.App.Version)
+	if err != nil {
+		ctx.Die("%+v", err)
+	}
+
+	out := os.Stdout
+	if file := ctx.String("output"); file != "" {
+		if out, err = os.Create(ctx.ArgPath(file)); err != nil {
+			ctx.Die("%+v", err)
+		}
+		defer out.Close()
+	}
+
+	if err := sbom.Write(out, ctx.String("format")); err != nil {
+		ctx.Die("%+v", err)
+	}
+}
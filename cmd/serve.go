@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Serve 将本地cache共享为go get和git镜像
+type Serve struct {
+}
+
+func (self *Serve) Cmd() cli.Command {
+	return cli.Command{
+		Name:  "serve",
+		Usage: "Share the local cache as a go-get and git mirror",
+		Description: `Answers ?go-get=1 meta requests for cached packages and serves the cached
+		repos over git smart http, so other hosts can clone through this one:
+
+		    gpm serve --addr :8080
+		    git clone http://host:8080/github.com/user/repo
+
+		With --goproxy the GOPROXY protocol (list, info, mod, zip, @latest) is also
+		served for semver tags of cached repos.
+
+		Requests are answered from an index of the cache, built at start and
+		rebuilt every --refresh, packages fetched meanwhile are served after it.`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "addr",
+				Value: ":8080",
+				Usage: "listen address",
+			},
+			cli.BoolFlag{
+				Name:  "goproxy",
+				Usage: "also serve GOPROXY protocol",
+			},
+			cli.DurationFlag{
+				Name:  "refresh",
+				Value: gpm.DefaultRefresh,
+				Usage: "interval of rescanning the cache, 0 never",
+			},
+		},
+	}
+}
+
+// Run serve cache dir
+func (self *Serve) Run(ctx *gpm.Ctx) {
+	server := gpm.NewServer(ctx.CacheDir, ctx.Logger)
+	server.Proxy = ctx.Bool("goproxy")
+	server.Refresh = ctx.Duration("refresh")
+	if err := server.ListenAndServe(ctx.String("addr")); err != nil {
+		ctx.Die("serve fail:%+v", err)
+	}
+}
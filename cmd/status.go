@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"os/exec"
+	"path/filepath"
+
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Status 显示依赖的安装状态
+type Status struct {
+}
+
+func (self *Status) Cmd() cli.Command {
+	return cli.Command{
+		Name:        "status",
+		ShortName:   "st",
+		Usage:       "Show the locked and vendored state of dependencies",
+		Description: "",
+	}
+}
+
+// Run print status of all deps
+func (self *Status) Run(ctx *gpm.Ctx) {
+	ctx.MustLoad()
+
+	for _, dep := range ctx.Imports {
+		state := "ok"
+		if !gpm.Exists(filepath.Join("vendor", dep.Name)) {
+			state = "missing"
+		}
+
+		lock := ctx.LockFile.Find(dep.Name)
+		switch {
+		case lock == nil:
+			ctx.Puts("%s\t%s\tnot locked", dep.Name, state)
+		case lock.IsReplaced():
+			ctx.Puts("%s\t%s\treplaced by %s", dep.Name, state, lock.Replace)
+		default:
+			ctx.Puts("%s\t%s\t%s", dep.Name, state, lock.Reversion)
+		}
+	}
+
+	for _, r := range committedLocalReplaces() {
+		ctx.Warn("local replace is committed: %s => %s", r.Name, r.Path)
+	}
+}
+
+// 查找git HEAD中gpm.yaml包含的本地replace
+func committedLocalReplaces() []*gpm.Replace {
+	out, err := exec.Command("git", "show", "HEAD:./"+gpm.ConfName).Output()
+	if err != nil {
+		return nil
+	}
+
+	cfg := gpm.NewConfig()
+	if err := yaml.Unmarshal(out, cfg); err != nil {
+		return nil
+	}
+
+	result := []*gpm.Replace{}
+	for _, r := range cfg.Replaces {
+		if r.IsLocal() {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
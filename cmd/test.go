@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Test 测试项目自身的包,不包含vendor
+type Test struct {
+}
+
+func (self *Test) Cmd() cli.Command {
+	return cli.Command{
+		Name:      "test",
+		Usage:     "Test packages of the project, vendor excluded, with coverage",
+		ArgsUsage: "[--run regexp] [--race] [--count n] [packages...]",
+		Description: `Runs go test on packages of the project, vendor/, dist/, testdata and
+		dirs of test.ignore in gpm.yaml are skipped:
+
+		    test:
+		      ignore: [tools, examples]
+
+		go test runs in the environment of gpm build. Coverage profiles are merged
+		into --coverprofile, coverage of each package and the total is printed.
+		--junit writes test results as JUnit XML. The exit status of go test is
+		returned.`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "run",
+				Usage: "run only tests matching the regexp",
+			},
+			cli.BoolFlag{
+				Name:  "race",
+				Usage: "enable data race detection",
+			},
+			cli.StringFlag{
+				Name:  "count",
+				Usage: "run each test n times, 1 disables test cache",
+			},
+			cli.BoolFlag{
+				Name:  "verbose, v",
+				Usage: "print output of all tests",
+			},
+			cli.StringFlag{
+				Name:  "coverprofile",
+				Value: "coverage.out",
+				Usage: "file of merged coverage profile",
+			},
+			cli.StringFlag{
+				Name:  "junit",
+				Usage: "write results as JUnit XML to file",
+			},
+		},
+	}
+}
+
+// Run 执行go test并汇总覆盖率
+func (self *Test) Run(ctx *gpm.Ctx) {
+	ctx.MustLoad()
+
+	packages := []string(ctx.Args())
+	if len(packages) == 0 {
+		ignore := []string{}
+		if ctx.Tests != nil {
+			ignore = ctx.Tests.Ignore
+		}
+
+		var err error
+		if packages, err = gpm.ProjectPackages(ctx.WorkDir, ignore); err != nil {
+			ctx.Die("%+v", err)
+		}
+	}
+
+	if len(packages) == 0 {
+		ctx.Die("no package to test")
+	}
+
+	dir, env, err := ctx.GoEnv("")
+	if err != nil {
+		ctx.Die("%+v", err)
+	}
+
+	tmp, err := gpm.TempDir("test")
+	if err != nil {
+		ctx.Die("%+v", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	profile := filepath.Join(tmp, "cover.out")
+	args := []string{"test", "-json", "-coverprofile", profile}
+	if ctx.Bool("race") {
+		args = append(args, "-race", "-covermode", "atomic")
+	}
+
+	if run := ctx.String("run"); run != "" {
+		args = append(args, "-run", run)
+	}
+
+	if count := ctx.String("count"); count != "" {
+		args = append(args, "-count", count)
+	}
+
+	// output of go test -json is streamed as text
+	cmd := exec.Command("go", append(args, packages...)...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		ctx.Die("%+v", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		ctx.Die("%+v", err)
+	}
+
+	results, readErr := gpm.ReadTestEvents(stdout, os.Stdout, ctx.Bool("verbose"))
+	runErr := cmd.Wait()
+	if readErr != nil {
+		ctx.Die("read go test output fail:%+v", readErr)
+	}
+
+	// merged coverage
+	coverage := map[string]float64{}
+	total := -1.0
+	if gpm.Exists(profile) {
+		p := &gpm.CoverProfile{}
+		if err := p.ReadCoverProfile(profile); err != nil {
+			ctx.Die("%+v", err)
+		}
+
+		out := ctx.ArgPath(ctx.String("coverprofile"))
+		if err := p.WriteFile(out); err != nil {
+			ctx.Die("write %s fail:%+v", out, err)
+		}
+
+		coverage, total = p.Coverage()
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "PACKAGE\tSTATUS\tTIME\tCOVERAGE")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%.1fs\t%s\n", r.Package, r.Action, r.Elapsed, self.percent(coverage, r.Package))
+	}
+	fmt.Fprintf(w, "total\t\t\t%s\n", self.percent(map[string]float64{"": total}, ""))
+	w.Flush()
+
+	if file := ctx.String("junit"); file != "" {
+		if err := gpm.WriteJUnit(ctx.ArgPath(file), results); err != nil {
+			ctx.Die("write junit fail:%+v", err)
+		}
+	}
+
+	if runErr != nil {
+		code := 1
+		if ee, ok := runErr.(*exec.ExitError); ok {
+			code = ee.ExitCode()
+		}
+		ctx.Exit(code, "go test fail:%+v", runErr)
+	}
+}
+
+func (self *Test) percent(coverage map[string]float64, pkg string) string {
+	value, ok := coverage[pkg]
+	if !ok || value < 0 {
+		return "-"
+	}
+
+	return fmt.Sprintf("%.1f%%", value)
+}
@@ -19,11 +19,22 @@ func (self *Update) Cmd() cli.Command {
 
 // Run update all deps and update lock file
 func (self *Update) Run(ctx *gpm.Ctx) {
+	ctx.FetchBases = true
 	ctx.MustLoad()
 
+	ctx.LockFile = gpm.NewLockFile()
 	for _, dep := range ctx.Imports {
-		if err := ctx.Get(dep.Remote(), dep.Version); err != nil {
+		lock, err := ctx.GetDependency(dep, dep.Version)
+		if err != nil {
 			ctx.Die("%+v", err)
 		}
+
+		ctx.LockFile.Set(lock)
+	}
+
+	ctx.SaveLock()
+
+	if err := ctx.CheckLicenses(); err != nil {
+		ctx.Die("%+v", err)
 	}
 }
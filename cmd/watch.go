@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/jeckbjy/gpm/gpm"
+)
+
+// Watch 监听源码变化,重新编译并重启程序
+type Watch struct {
+	mu       sync.Mutex
+	proc     *exec.Cmd
+	done     chan struct{}
+	stopping bool
+}
+
+func (self *Watch) Cmd() cli.Command {
+	return cli.Command{
+		Name:      "watch",
+		Usage:     "Rebuild and restart a target when sources change",
+		ArgsUsage: "[--args <args>] [target]",
+		Description: `Builds the target for the host platform like gpm build and runs it in the
+		project root with --args. .go files of the project (vendor, dist and hidden
+		dirs excluded) and gpm.yaml are polled, changes are collected until files
+		are quiet for --debounce, then the target is rebuilt and restarted.
+
+		If gpm.yaml changed, dependencies are installed first like gpm install.
+		Build errors are shown and the running program is kept until a build
+		succeeds. The program is stopped by interrupt, killed after 5 seconds.
+
+		    gpm watch --args "-port 8080" server`,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "args, a",
+				Usage: "arguments of the program, split by spaces",
+			},
+			cli.StringFlag{
+				Name:  "gopath, g",
+				Usage: "gopath for build, default is the private .gpm/gopath if project is not in a GOPATH",
+			},
+			cli.DurationFlag{
+				Name:  "interval",
+				Value: 500 * time.Millisecond,
+				Usage: "poll interval of files",
+			},
+			cli.DurationFlag{
+				Name:  "debounce",
+				Value: 300 * time.Millisecond,
+				Usage: "wait files quiet for this time before rebuild",
+			},
+		},
+	}
+}
+
+// Run build and run target, rebuild and restart it when files change
+// watch [--args <args>] [target]
+func (self *Watch) Run(ctx *gpm.Ctx) {
+	if len(ctx.Args()) > 1 {
+		ctx.Die("watch need at most one target")
+	}
+
+	ctx.MustLoad()
+
+	name := ""
+	if len(ctx.Args()) == 1 {
+		name = ctx.Args()[0]
+	}
+
+	if self.target(ctx, name) == nil {
+		ctx.Die("cannot find build target:%+v", name)
+	}
+
+	// stop program with gpm
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		self.stop(ctx)
+		os.Exit(0)
+	}()
+
+	args := strings.Fields(ctx.String("args"))
+	self.rebuild(ctx, name, args)
+
+	watcher := gpm.NewWatcher(ctx.WorkDir, self.ignore(ctx), ctx.Duration("interval"), ctx.Duration("debounce"))
+	for {
+		changed := watcher.Wait()
+		ctx.Info("--> Changed %s", strings.Join(changed, ", "))
+
+		if self.changed(changed, gpm.ConfName) {
+			ok := self.try(ctx, func() {
+				ctx.MustLoad()
+				(&Install{}).install(ctx)
+			})
+			watcher.Ignore = self.ignore(ctx)
+
+			if !ok {
+				ctx.Warn("install fail, watching for changes")
+				continue
+			}
+		}
+
+		self.rebuild(ctx, name, args)
+	}
+}
+
+// ignore returns files not watched, generated stamp file changes every build
+func (self *Watch) ignore(ctx *gpm.Ctx) []string {
+	if ctx.Stamp != nil && ctx.Stamp.File != "" {
+		return []string{ctx.Stamp.File}
+	}
+
+	return nil
+}
+
+// changed returns true if file is in changed files
+func (self *Watch) changed(changed []string, file string) bool {
+	for _, f := range changed {
+		if f == file {
+			return true
+		}
+	}
+
+	return false
+}
+
+// target returns the target to watch, the only target if name is empty
+func (self *Watch) target(ctx *gpm.Ctx, name string) *gpm.Target {
+	if name != "" {
+		return ctx.FindTarget(name)
+	}
+
+	switch len(ctx.Targets) {
+	case 0:
+		return (&Build{}).defaultTarget(ctx)
+	case 1:
+		return ctx.Targets[0]
+	}
+
+	names := []string{}
+	for _, t := range ctx.Targets {
+		names = append(names, t.Name)
+	}
+	ctx.Die("watch need a target of %s", strings.Join(names, ", "))
+	return nil
+}
+
+// rebuild build target, restart program if succeed
+func (self *Watch) rebuild(ctx *gpm.Ctx, name string, args []string) {
+	var artifacts []*gpm.Artifact
+	ok := self.try(ctx, func() {
+		target := self.target(ctx, name)
+		if target == nil {
+			ctx.Die("cannot find build target:%+v", name)
+		}
+
+		artifacts = (&Build{}).build(ctx, []*gpm.Target{target}, false, ctx.String("gopath"), &gpm.BuildOptions{})
+	})
+
+	if !ok {
+		ctx.Warn("build fail, watching for changes")
+		return
+	}
+
+	self.stop(ctx)
+	self.start(ctx, filepath.Join(ctx.WorkDir, artifacts[0].Path), args)
+}
+
+// try run fn, returns false if it dies, the watcher keeps running
+func (self *Watch) try(ctx *gpm.Ctx, fn func()) (ok bool) {
+	ctx.PanicOnDie = true
+	defer func() {
+		ctx.PanicOnDie = false
+		if r := recover(); r != nil {
+			if r != "trapped" {
+				panic(r)
+			}
+			ok = false
+		}
+	}()
+
+	fn()
+	return true
+}
+
+// start run program in project root
+func (self *Watch) start(ctx *gpm.Ctx, path string, args []string) {
+	self.mu.Lock()
+	defer self.mu.Unlock()
+
+	ctx.Info("--> Run %s %s", ctx.RelPath(path), strings.Join(args, " "))
+	proc := exec.Command(path, args...)
+	proc.Dir = ctx.WorkDir
+	proc.Stdin = os.Stdin
+	proc.Stdout = os.Stdout
+	proc.Stderr = os.Stderr
+	if err := proc.Start(); err != nil {
+		ctx.Error("run %s fail:%+v", path, err)
+		return
+	}
+
+	done := make(chan struct{})
+	self.proc, self.done, self.stopping = proc, done, false
+	go func() {
+		err := proc.Wait()
+
+		self.mu.Lock()
+		stopping := self.stopping && self.proc == proc
+		self.mu.Unlock()
+
+		if !stopping {
+			if err != nil {
+				ctx.Warn("--> %s exited:%+v", filepath.Base(path), err)
+			} else {
+				ctx.Info("--> %s exited", filepath.Base(path))
+			}
+		}
+		close(done)
+	}()
+}
+
+// stop interrupt program and wait it exit, kill it after timeout
+func (self *Watch) stop(ctx *gpm.Ctx) {
+	self.mu.Lock()
+	proc, done := self.proc, self.done
+	self.stopping = true
+	self.mu.Unlock()
+
+	if proc == nil {
+		return
+	}
+
+	select {
+	case <-done:
+		return
+	default:
+	}
+
+	// interrupt is not supported on windows
+	if err := proc.Process.Signal(os.Interrupt); err != nil {
+		proc.Process.Kill()
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		ctx.Warn("--> Kill %s", filepath.Base(proc.Path))
+		proc.Process.Kill()
+		<-done
+	}
+}
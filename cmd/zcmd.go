@@ -15,15 +15,31 @@ type Command interface {
 func New() []Command {
 	cmds := []Command{
 		&About{},
+		&Add{},
+		&Audit{},
 		&Build{},
+		&Bundle{},
+		&Config{},
 		&Create{},
+		&Exec{},
 		&Get{},
 		&Info{},
 		&Install{},
+		&Licenses{},
+		&Lint{},
 		&List{},
 		&Name{},
+		&Patch{},
+		&Release{},
 		&Remove{},
+		&Replace{},
+		&Run{},
+		&Sbom{},
+		&Serve{},
+		&Status{},
+		&Test{},
 		&Update{},
+		&Watch{},
 	}
 
 	return cmds
@@ -32,6 +32,24 @@ func main() {
 	app.Name = "gpm"
 	app.Usage = usage
 	app.Version = version
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "C, project",
+			Usage: "run as if gpm was started in dir, gpm.yaml is searched upward from it",
+		},
+		cli.StringFlag{
+			Name:  "cache-dir",
+			Usage: "cache dir, overrides setting cache",
+		},
+		cli.StringFlag{
+			Name:  "color",
+			Usage: "auto, always or never, overrides setting color",
+		},
+		cli.StringFlag{
+			Name:  "jobs, j",
+			Usage: "parallel jobs, overrides setting jobs",
+		},
+	}
 
 	// setup commands
 	cmds := cmd.New()
@@ -41,6 +59,14 @@ func main() {
 		cliCmd.Action = func(cliCtx *cli.Context) error {
 			ctx := gpm.NewCtx()
 			ctx.Context = cliCtx
+			if dir := cliCtx.GlobalString("C"); dir != "" {
+				if err := ctx.ChangeDir(dir); err != nil {
+					ctx.Die("change dir fail:%+v", err)
+				}
+			}
+
+			// settings are loaded once, paths of env and flags are relative to -C
+			ctx.LoadSettings()
 
 			// ctx.Debug("run cmd:%+s", cliCtx.Command.Name)
 			action.Run(ctx)
@@ -0,0 +1,370 @@
+package gpm
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// archive types
+const (
+	ArchiveTarGz = ".tar.gz"
+	ArchiveZip   = ".zip"
+)
+
+// DownloadTimeout is the time limit of downloading an archive
+const DownloadTimeout = 10 * time.Minute
+
+// httpClient downloads archives, proxy of environment is used
+var httpClient = &http.Client{Timeout: DownloadTimeout}
+
+// ArchiveType returns archive type by url suffix, empty if not supported
+func ArchiveType(url string) string {
+	url = strings.ToLower(url)
+	if i := strings.IndexAny(url, "?#"); i != -1 {
+		url = url[:i]
+	}
+
+	switch {
+	case strings.HasSuffix(url, ".tar.gz"), strings.HasSuffix(url, ".tgz"):
+		return ArchiveTarGz
+	case strings.HasSuffix(url, ".zip"):
+		return ArchiveZip
+	}
+
+	return ""
+}
+
+// fetchArchive download archive to cache, verify checksum and unpack to vendor/name
+func (ctx *Ctx) fetchArchive(dep *Dependency) error {
+	file, err := ctx.cacheArchive(dep)
+	if err != nil {
+		return err
+	}
+
+	dir, _ := filepath.Abs(filepath.Join("vendor", dep.Name))
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+
+	ctx.Info("--> Unpack %s, %s", dep.Name, dir)
+	return Unpack(file, dir, dep.StripPrefix)
+}
+
+// cacheArchive returns the verified archive in cache, download if not exist
+func (ctx *Ctx) cacheArchive(dep *Dependency) (string, error) {
+	if dep.Sha256 == "" {
+		return "", fmt.Errorf("archive dependency need sha256:%+v", dep.Name)
+	}
+
+	if ArchiveType(dep.Archive) == "" {
+		return "", fmt.Errorf("unsupported archive:%+v", dep.Archive)
+	}
+
+	sum := strings.ToLower(dep.Sha256)
+	file := ctx.ArchivePath(dep)
+	if actual, err := HashFile(file); err == nil && actual == sum {
+		return file, nil
+	}
+
+	if ctx.Offline {
+		return "", fmt.Errorf("%s not in cache, cannot download in offline mode", dep.Archive)
+	}
+
+	ctx.Info("--> Download %s", dep.Archive)
+	if err := Download(dep.Archive, file, sum); err != nil {
+		return "", err
+	}
+
+	return file, nil
+}
+
+// ArchivePath returns the path of archive in cache dir
+func (ctx *Ctx) ArchivePath(dep *Dependency) string {
+	return filepath.Join(ctx.CacheDir, "archives", strings.ToLower(dep.Sha256)+ArchiveType(dep.Archive))
+}
+
+// Download save url to file, the file is kept only if sha256 matched
+func Download(url string, file string, sum string) error {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s fail:%s", url, resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	tmp := file + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(out, h), resp.Body)
+	out.Close()
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != strings.ToLower(sum) {
+		os.Remove(tmp)
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, sum, actual)
+	}
+
+	return os.Rename(tmp, file)
+}
+
+// HashFile returns sha256 of file
+func HashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Unpack extract tar.gz or zip file to dir, entries outside of prefix are skipped
+func Unpack(file string, dir string, prefix string) error {
+	if ArchiveType(file) == ArchiveZip {
+		return unzip(file, dir, prefix)
+	}
+
+	return untar(file, dir, prefix)
+}
+
+func untar(file string, dir string, prefix string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		target, ok := unpackPath(dir, hdr.Name, prefix)
+		if !ok {
+			continue
+		}
+
+		if err := checkUnpackPath(dir, target); err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeFile(target, tr, os.FileMode(hdr.Mode))
+		case tar.TypeSymlink:
+			err = writeSymlink(dir, target, hdr.Linkname)
+		case tar.TypeLink:
+			// hard link to an entry unpacked before, copied
+			src, ok := unpackPath(dir, hdr.Linkname, prefix)
+			if !ok {
+				return fmt.Errorf("hard link %s to %s outside of strip-prefix", hdr.Name, hdr.Linkname)
+			}
+
+			if err = checkUnpackPath(dir, src); err == nil {
+				err = copyUnpacked(src, target, os.FileMode(hdr.Mode))
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func unzip(file string, dir string, prefix string) error {
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, zf := range r.File {
+		target, ok := unpackPath(dir, zf.Name, prefix)
+		if !ok {
+			continue
+		}
+
+		if err := checkUnpackPath(dir, target); err != nil {
+			return err
+		}
+
+		if zf.FileInfo().IsDir() {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return err
+		}
+
+		err = writeFile(target, rc, zf.Mode())
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unpackPath strip prefix from name and join with dir, reject path outside of dir
+func unpackPath(dir string, name string, prefix string) (string, bool) {
+	name = path.Clean("/" + strings.Replace(name, "\\", "/", -1))[1:]
+	if prefix != "" {
+		prefix = strings.Trim(path.Clean("/"+prefix), "/")
+		if name != prefix && !strings.HasPrefix(name, prefix+"/") {
+			return "", false
+		}
+
+		name = strings.TrimPrefix(name[len(prefix):], "/")
+	}
+
+	if name == "" {
+		return dir, true
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(name)), true
+}
+
+// checkUnpackPath returns error if target or any parent of it under dir is a symlink,
+// a link unpacked before must not redirect later entries out of dir
+func checkUnpackPath(dir string, target string) error {
+	rel, err := filepath.Rel(dir, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside of %s", target, dir)
+	}
+
+	cur := dir
+	for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+		if elem == "." {
+			continue
+		}
+
+		cur = filepath.Join(cur, elem)
+		fi, err := os.Lstat(cur)
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("refuse to write through symlink %s", cur)
+		}
+	}
+
+	return nil
+}
+
+// writeSymlink create symlink at target, link must be relative and stay in dir.
+// link is followed from the real parent of target, and must be clean so that .. is
+// only in front, a later component cannot go up through a link unpacked before
+func writeSymlink(dir string, target string, link string) error {
+	slashed := filepath.ToSlash(link)
+	if link == "" || filepath.IsAbs(link) || path.IsAbs(slashed) || path.Clean(slashed) != slashed {
+		return fmt.Errorf("unsafe symlink %s -> %s", target, link)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return err
+	}
+
+	parent, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+
+	resolved := filepath.Join(parent, filepath.FromSlash(slashed))
+	if rel, err := filepath.Rel(root, resolved); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("unsafe symlink %s -> %s, points outside of %s", target, link, dir)
+	}
+
+	return os.Symlink(link, target)
+}
+
+// copyUnpacked copy an unpacked file to target
+func copyUnpacked(src string, target string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	return writeFile(target, in, mode)
+}
+
+func writeFile(target string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+
+	if mode&0777 == 0 {
+		mode = 0644
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode&0777)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
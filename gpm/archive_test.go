@@ -0,0 +1,275 @@
+package gpm
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fixtureEntry is a file of archive fixture, link is target of symlink
+type fixtureEntry struct {
+	Name string
+	Body string
+	Link string
+}
+
+func tarGzFixture(t *testing.T, entries []fixtureEntry) []byte {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.Name, Mode: 0644, Size: int64(len(e.Body)), Typeflag: tar.TypeReg}
+		switch {
+		case e.Link != "":
+			hdr.Typeflag, hdr.Linkname, hdr.Size, hdr.Mode = tar.TypeSymlink, e.Link, 0, 0777
+		case strings.HasSuffix(e.Name, "/"):
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0755
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(e.Body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func zipFixture(t *testing.T, entries []fixtureEntry) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, e := range entries {
+		w, err := zw.Create(e.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write([]byte(e.Body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gpm-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func readString(t *testing.T, file string) string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+// serveFixtures serve path -> content, requests are counted
+func serveFixtures(files map[string][]byte, requests *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write(data)
+	}))
+}
+
+var libFixture = []fixtureEntry{
+	{Name: "lib-1.2.3/"},
+	{Name: "lib-1.2.3/lib.go", Body: "package lib\n"},
+	{Name: "lib-1.2.3/sub/sub.go", Body: "package sub\n"},
+}
+
+func TestArchiveType(t *testing.T) {
+	cases := map[string]string{
+		"https://example.com/lib-1.2.3.tar.gz":      ArchiveTarGz,
+		"https://example.com/lib-1.2.3.TGZ":         ArchiveTarGz,
+		"https://example.com/lib.zip?token=1#frag":  ArchiveZip,
+		"https://example.com/lib-1.2.3.tar.bz2":     "",
+		"https://example.com/download?file=lib.zip": "",
+	}
+
+	for url, expected := range cases {
+		if actual := ArchiveType(url); actual != expected {
+			t.Errorf("ArchiveType(%s) = %q, expected %q", url, actual, expected)
+		}
+	}
+}
+
+func TestCacheArchive(t *testing.T) {
+	tgz := tarGzFixture(t, libFixture)
+	zipped := zipFixture(t, libFixture[1:])
+	requests := 0
+	server := serveFixtures(map[string][]byte{"/lib-1.2.3.tar.gz": tgz, "/lib-1.2.3.zip": zipped}, &requests)
+	defer server.Close()
+
+	cache := tempDir(t)
+	defer os.RemoveAll(cache)
+
+	ctx := &Ctx{Logger: NewLogger(), CacheDir: cache}
+	for _, tc := range []struct {
+		url  string
+		data []byte
+	}{{server.URL + "/lib-1.2.3.tar.gz", tgz}, {server.URL + "/lib-1.2.3.zip", zipped}} {
+		dep := &Dependency{Name: "example.com/lib", Archive: tc.url, Sha256: strings.ToUpper(sha256Hex(tc.data)), StripPrefix: "lib-1.2.3"}
+		requests = 0
+		file, err := ctx.cacheArchive(dep)
+		if err != nil {
+			t.Fatalf("download %s fail:%+v", tc.url, err)
+		}
+
+		if file != ctx.ArchivePath(dep) || requests != 1 {
+			t.Fatalf("archive %s, requests %d", file, requests)
+		}
+
+		// cached, verified without download, also offline
+		ctx.Offline = true
+		if _, err := ctx.cacheArchive(dep); err != nil || requests != 1 {
+			t.Fatalf("cached archive is downloaded again: %d, %+v", requests, err)
+		}
+		ctx.Offline = false
+
+		dir := filepath.Join(cache, "vendor"+ArchiveType(tc.url))
+		if err := Unpack(file, dir, dep.StripPrefix); err != nil {
+			t.Fatal(err)
+		}
+
+		if s := readString(t, filepath.Join(dir, "sub", "sub.go")); s != "package sub\n" {
+			t.Errorf("unpack %s: %q", tc.url, s)
+		}
+	}
+}
+
+func TestCacheArchiveChecksumMismatch(t *testing.T) {
+	requests := 0
+	server := serveFixtures(map[string][]byte{"/lib.tar.gz": tarGzFixture(t, libFixture)}, &requests)
+	defer server.Close()
+
+	cache := tempDir(t)
+	defer os.RemoveAll(cache)
+
+	ctx := &Ctx{Logger: NewLogger(), CacheDir: cache}
+	dep := &Dependency{Name: "example.com/lib", Archive: server.URL + "/lib.tar.gz", Sha256: sha256Hex([]byte("other"))}
+	if _, err := ctx.cacheArchive(dep); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %+v", err)
+	}
+
+	if Exists(ctx.ArchivePath(dep)) || Exists(ctx.ArchivePath(dep)+".tmp") {
+		t.Fatal("archive with wrong checksum is kept")
+	}
+
+	dep.Archive = server.URL + "/missing.tar.gz"
+	if _, err := ctx.cacheArchive(dep); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected 404, got %+v", err)
+	}
+
+	// not cached, never downloaded offline
+	requests = 0
+	ctx.Offline = true
+	if _, err := ctx.cacheArchive(dep); err == nil || requests != 0 {
+		t.Fatalf("offline mode downloads: %d, %+v", requests, err)
+	}
+}
+
+func TestUnpackSymlinks(t *testing.T) {
+	cases := []struct {
+		name    string
+		entries []fixtureEntry
+		err     string
+	}{
+		{"relative", []fixtureEntry{{Name: "a/lib.go", Body: "package a\n"}, {Name: "b/lib.go", Link: "../a/lib.go"}}, ""},
+		{"absolute", []fixtureEntry{{Name: "passwd", Link: "/etc/passwd"}}, "unsafe symlink"},
+		{"escape", []fixtureEntry{{Name: "a/up", Link: "../../.."}}, "unsafe symlink"},
+		{"chain", []fixtureEntry{{Name: "l", Link: "d/.."}, {Name: "d", Link: "."}}, "unsafe symlink"},
+		{"chain deep", []fixtureEntry{{Name: "a/b/", Body: ""}, {Name: "a/b/up", Link: ".."}, {Name: "a/x", Link: "b/up/../.."}}, "unsafe symlink"},
+		{"write through", []fixtureEntry{{Name: "a/", Body: ""}, {Name: "a/self", Link: "."}, {Name: "a/self/x.go", Body: "package a\n"}}, "refuse to write through symlink"},
+	}
+
+	for _, tc := range cases {
+		root := tempDir(t)
+		file := filepath.Join(root, "fixture.tar.gz")
+		if err := ioutil.WriteFile(file, tarGzFixture(t, tc.entries), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		dir := filepath.Join(root, "vendor")
+		err := Unpack(file, dir, "")
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s: %+v", tc.name, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%s: expected %q, got %+v", tc.name, tc.err, err)
+		case tc.err == "" && readString(t, filepath.Join(dir, "b", "lib.go")) != "package a\n":
+			t.Errorf("%s: symlink not unpacked", tc.name)
+		}
+
+		os.RemoveAll(root)
+	}
+}
+
+func TestUnpackPath(t *testing.T) {
+	dir := filepath.FromSlash("/vendor/lib")
+	cases := []struct {
+		name, prefix, expected string
+		ok                     bool
+	}{
+		{"lib-1.0/a.go", "lib-1.0", "a.go", true},
+		{"lib-1.0", "lib-1.0/", "", true},
+		{"other/a.go", "lib-1.0", "", false},
+		{"lib-1.0x/a.go", "lib-1.0", "", false},
+		{"../../etc/passwd", "", "etc/passwd", true},
+		{`lib-1.0\sub\a.go`, "lib-1.0", "sub/a.go", true},
+	}
+
+	for _, tc := range cases {
+		target, ok := unpackPath(dir, tc.name, tc.prefix)
+		if ok != tc.ok {
+			t.Errorf("unpackPath(%s, %s) ok = %v", tc.name, tc.prefix, ok)
+			continue
+		}
+
+		if ok && target != filepath.Join(dir, filepath.FromSlash(tc.expected)) {
+			t.Errorf("unpackPath(%s, %s) = %s", tc.name, tc.prefix, target)
+		}
+	}
+}
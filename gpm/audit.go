@@ -0,0 +1,480 @@
+package gpm
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/Masterminds/vcs"
+)
+
+// severity levels, unknown severity is ranked as audit.unknown
+var severityLevels = []string{"NONE", "LOW", "MEDIUM", "HIGH", "CRITICAL"}
+
+// version in file name of archive url, like lib-1.2.3.tar.gz or v1.2.zip
+var archiveVersionRe = regexp.MustCompile(`(?:^|[-_v])(\d+\.\d+(?:\.\d+)?(?:-[0-9A-Za-z.]+)?)\.(?:tar\.gz|tgz|zip)$`)
+
+// OsvEvent is an event of affected range
+type OsvEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+}
+
+// OsvRange is a range of affected versions or commits
+type OsvRange struct {
+	Type   string     `json:"type"` // SEMVER, ECOSYSTEM or GIT
+	Repo   string     `json:"repo,omitempty"`
+	Events []OsvEvent `json:"events"`
+}
+
+// OsvAffected is an affected package of advisory
+type OsvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges   []OsvRange `json:"ranges"`
+	Versions []string   `json:"versions"`
+}
+
+// Advisory is a vulnerability in OSV format
+type Advisory struct {
+	ID       string        `json:"id"`
+	Summary  string        `json:"summary"`
+	Aliases  []string      `json:"aliases"`
+	Affected []OsvAffected `json:"affected"`
+	Scores   []struct {
+		Type  string `json:"type"`
+		Score string `json:"score"`
+	} `json:"severity"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// Finding is an advisory which affects a locked dependency
+type Finding struct {
+	Name     string
+	Version  string // semver tag or reversion of dependency
+	Advisory *Advisory
+	Fixed    string // smallest fixed version above current, empty if unknown
+}
+
+// LoadAdvisories load OSV json files from dir or zip
+func LoadAdvisories(path string) ([]*Advisory, error) {
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		return loadAdvisoriesZip(path)
+	}
+
+	result := []*Advisory{}
+	err := filepath.Walk(path, func(file string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() || !strings.HasSuffix(fi.Name(), ".json") {
+			return err
+		}
+
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		adv := &Advisory{}
+		if err := json.Unmarshal(data, adv); err != nil {
+			return fmt.Errorf("parse advisory %s fail:%+v", file, err)
+		}
+
+		result = append(result, adv)
+		return nil
+	})
+
+	return result, err
+}
+
+func loadAdvisoriesZip(file string) ([]*Advisory, error) {
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	result := []*Advisory{}
+	for _, zf := range r.File {
+		if zf.FileInfo().IsDir() || !strings.HasSuffix(zf.Name, ".json") {
+			continue
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		adv := &Advisory{}
+		err = json.NewDecoder(rc).Decode(adv)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("parse advisory %s fail:%+v", zf.Name, err)
+		}
+
+		result = append(result, adv)
+	}
+
+	return result, nil
+}
+
+// MatchAdvisories match locked dependencies against advisories, no network is used
+func (ctx *Ctx) MatchAdvisories(advisories []*Advisory) []*Finding {
+	result := []*Finding{}
+	for _, dep := range ctx.Imports {
+		lock := ctx.LockFile.Find(dep.Name)
+		if lock == nil || lock.Reversion == "" {
+			ctx.Warn("dependency not locked, skip audit:%+v", dep.Name)
+			continue
+		}
+
+		repo := ctx.cacheRepo(dep)
+		version := lock.Version
+		if version == "" && repo != nil {
+			if tags, err := repo.TagsFromCommit(lock.Reversion); err == nil {
+				version = SemverTag(tags)
+			}
+		}
+
+		// archive has no repo, only the version of locked url can match
+		if version == "" && lock.Archive != "" {
+			version = ArchiveVersion(lock.Archive)
+			if version == "" {
+				ctx.Warn("cannot get version of archive, skip audit:%+v", lock.Archive)
+				continue
+			}
+		}
+
+		for _, adv := range advisories {
+			for _, affected := range adv.Affected {
+				if !matchPackage(dep.Name, affected.Package.Name) {
+					continue
+				}
+
+				hit, fixed := affected.match(version, lock.Reversion, repo)
+				if hit {
+					f := &Finding{Name: dep.Name, Version: version, Advisory: adv, Fixed: fixed}
+					if f.Version == "" {
+						f.Version = lock.Reversion
+					}
+					result = append(result, f)
+					break
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// ArchiveVersion returns version in file name of archive url, empty if not found
+func ArchiveVersion(url string) string {
+	if i := strings.IndexAny(url, "?#"); i != -1 {
+		url = url[:i]
+	}
+
+	m := archiveVersionRe.FindStringSubmatch(strings.ToLower(path.Base(url)))
+	if m == nil {
+		return ""
+	}
+
+	if _, err := semver.NewVersion(m[1]); err != nil {
+		return ""
+	}
+
+	return m[1]
+}
+
+// cacheRepo returns repo in cache without network, nil if not cached
+func (ctx *Ctx) cacheRepo(dep *Dependency) vcs.Repo {
+	if dep.IsArchive() {
+		return nil
+	}
+
+	url := ctx.RemoteOf(dep)
+	local, err := ctx.CacheLocal(url)
+	if err != nil || !Exists(local) {
+		return nil
+	}
+
+	offline := ctx.Offline
+	ctx.Offline = true
+	repo, err := ctx.newRepo(url, local)
+	ctx.Offline = offline
+	if err != nil {
+		return nil
+	}
+
+	return repo
+}
+
+// matchPackage returns true if dependency is or contains the affected package
+func matchPackage(dep string, pkg string) bool {
+	return dep == pkg || strings.HasPrefix(pkg, dep+"/") || strings.HasPrefix(dep, pkg+"/")
+}
+
+// match returns if version or reversion is affected, and the smallest fixed version above version
+func (a *OsvAffected) match(version string, reversion string, repo vcs.Repo) (bool, string) {
+	if version != "" && (hasString(a.Versions, version) || hasString(a.Versions, strings.TrimPrefix(version, "v"))) {
+		return true, ""
+	}
+
+	cur, _ := semver.NewVersion(version)
+	for _, r := range a.Ranges {
+		switch r.Type {
+		case "SEMVER", "ECOSYSTEM":
+			if cur != nil && semverAffected(cur, r.Events) {
+				return true, semverFixed(cur, r.Events)
+			}
+		case "GIT":
+			if repo != nil && commitAffected(repo, reversion, r.Events) {
+				return true, ""
+			}
+		}
+	}
+
+	return false, ""
+}
+
+// semverAffected evaluate events sorted by version
+func semverAffected(v *semver.Version, events []OsvEvent) bool {
+	type point struct {
+		ver *semver.Version
+		ev  OsvEvent
+	}
+
+	points := []point{}
+	for _, ev := range events {
+		s := ev.Introduced + ev.Fixed + ev.LastAffected
+		if s == "0" {
+			s = "0.0.0"
+		}
+
+		if pv, err := semver.NewVersion(s); err == nil {
+			points = append(points, point{pv, ev})
+		}
+	}
+
+	sort.SliceStable(points, func(i, j int) bool { return points[i].ver.LessThan(points[j].ver) })
+
+	affected := false
+	for _, p := range points {
+		switch {
+		case p.ev.Introduced != "":
+			if !v.LessThan(p.ver) {
+				affected = true
+			}
+		case p.ev.Fixed != "":
+			if !v.LessThan(p.ver) {
+				affected = false
+			}
+		case p.ev.LastAffected != "":
+			if v.GreaterThan(p.ver) {
+				affected = false
+			}
+		}
+	}
+
+	return affected
+}
+
+// semverFixed returns the smallest fixed version above v
+func semverFixed(v *semver.Version, events []OsvEvent) string {
+	var found *semver.Version
+	for _, ev := range events {
+		if ev.Fixed == "" {
+			continue
+		}
+
+		fv, err := semver.NewVersion(ev.Fixed)
+		if err == nil && fv.GreaterThan(v) && (found == nil || fv.LessThan(found)) {
+			found = fv
+		}
+	}
+
+	if found == nil {
+		return ""
+	}
+
+	return found.String()
+}
+
+// commitAffected check reversion is after introduced and before fixed commit
+func commitAffected(repo vcs.Repo, reversion string, events []OsvEvent) bool {
+	ancestor := func(commit string) bool {
+		if commit == "0" {
+			return true
+		}
+
+		_, err := repo.RunFromDir("git", "merge-base", "--is-ancestor", commit, reversion)
+		return err == nil
+	}
+
+	introduced := false
+	for _, ev := range events {
+		if ev.Introduced != "" && ancestor(ev.Introduced) {
+			introduced = true
+		}
+	}
+
+	if !introduced {
+		return false
+	}
+
+	for _, ev := range events {
+		if ev.Fixed != "" && ancestor(ev.Fixed) {
+			return false
+		}
+
+		if ev.LastAffected != "" && ev.LastAffected != reversion && ancestor(ev.LastAffected) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Severity returns level of advisory: NONE, LOW, MEDIUM, HIGH, CRITICAL or UNKNOWN
+func (a *Advisory) Severity() string {
+	level := strings.ToUpper(a.DatabaseSpecific.Severity)
+	if level == "MODERATE" {
+		level = "MEDIUM"
+	}
+
+	if SeverityRank(level) > 0 {
+		return level
+	}
+
+	for _, s := range a.Scores {
+		if strings.HasPrefix(s.Type, "CVSS_V3") {
+			if score, ok := cvss3Score(s.Score); ok {
+				return cvssLevel(score)
+			}
+		}
+	}
+
+	return "UNKNOWN"
+}
+
+// SeverityRank returns order of severity level from 1 for NONE, 0 if invalid
+func SeverityRank(level string) int {
+	for i, l := range severityLevels {
+		if l == strings.ToUpper(level) {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+func cvssLevel(score float64) string {
+	switch {
+	case score >= 9:
+		return "CRITICAL"
+	case score >= 7:
+		return "HIGH"
+	case score >= 4:
+		return "MEDIUM"
+	case score > 0:
+		return "LOW"
+	}
+
+	return "NONE"
+}
+
+// cvss3Score calculate base score of CVSS v3 vector
+func cvss3Score(vector string) (float64, bool) {
+	metrics := map[string]string{}
+	for _, part := range strings.Split(vector, "/") {
+		kv := strings.SplitN(part, ":", 2)
+		if len(kv) == 2 {
+			metrics[kv[0]] = kv[1]
+		}
+	}
+
+	weights := map[string]map[string]float64{
+		"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+		"AC": {"L": 0.77, "H": 0.44},
+		"UI": {"N": 0.85, "R": 0.62},
+		"C":  {"H": 0.56, "L": 0.22, "N": 0},
+		"I":  {"H": 0.56, "L": 0.22, "N": 0},
+		"A":  {"H": 0.56, "L": 0.22, "N": 0},
+	}
+
+	w := map[string]float64{}
+	for name, values := range weights {
+		value, ok := values[metrics[name]]
+		if !ok {
+			return 0, false
+		}
+		w[name] = value
+	}
+
+	changed := metrics["S"] == "C"
+	pr := map[string]float64{"N": 0.85, "L": 0.62, "H": 0.27}
+	if changed {
+		pr = map[string]float64{"N": 0.85, "L": 0.68, "H": 0.5}
+	}
+
+	prw, ok := pr[metrics["PR"]]
+	if !ok {
+		return 0, false
+	}
+
+	iss := 1 - (1-w["C"])*(1-w["I"])*(1-w["A"])
+	impact := 6.42 * iss
+	if changed {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+
+	if impact <= 0 {
+		return 0, true
+	}
+
+	exploit := 8.22 * w["AV"] * w["AC"] * prw * w["UI"]
+	score := impact + exploit
+	if changed {
+		score *= 1.08
+	}
+
+	return math.Ceil(math.Min(score, 10)*10) / 10, true
+}
+
+// FixConstraint returns the smallest constraint change of dependency to reach fixed version,
+// empty if the current constraint already allows it
+func FixConstraint(constraint string, fixed string) string {
+	if c, err := semver.NewConstraint(constraint); err == nil {
+		if v, err := semver.NewVersion(fixed); err == nil && c.Check(v) && constraint != "" {
+			return ""
+		}
+	}
+
+	switch {
+	case constraint == "", strings.HasPrefix(constraint, "^"):
+		return "^" + fixed
+	case strings.HasPrefix(constraint, "~"):
+		return "~" + fixed
+	}
+
+	// exact version pin
+	if _, err := semver.NewVersion(constraint); err == nil {
+		if strings.HasPrefix(constraint, "v") {
+			return "v" + fixed
+		}
+		return fixed
+	}
+
+	return ">=" + fixed
+}
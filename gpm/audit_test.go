@@ -0,0 +1,93 @@
+package gpm
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestArchiveVersion(t *testing.T) {
+	cases := map[string]string{
+		"https://example.com/lib-1.2.3.tar.gz":          "1.2.3",
+		"https://example.com/v1.2.zip?token=1":          "1.2",
+		"https://example.com/lib_2.0.0-rc.1.tgz#x":      "2.0.0-rc.1",
+		"https://example.com/archive/1.0.0.tar.gz":      "1.0.0",
+		"https://example.com/lib-master.tar.gz":         "",
+		"https://example.com/1.2.3/lib.tar.gz":          "",
+		"https://example.com/download?file=lib-1.0.zip": "",
+	}
+
+	for url, expected := range cases {
+		if actual := ArchiveVersion(url); actual != expected {
+			t.Errorf("ArchiveVersion(%s) = %q, expected %q", url, actual, expected)
+		}
+	}
+}
+
+func TestMatchArchiveAdvisories(t *testing.T) {
+	adv := &Advisory{}
+	err := json.Unmarshal([]byte(`{"id":"GO-1","affected":[{"package":{"name":"example.com/lib"},"ranges":[{"type":"SEMVER","events":[{"introduced":"0"},{"fixed":"1.3.0"}]}]}]}`), adv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]int{
+		"https://example.com/lib-1.2.3.tar.gz": 1,
+		"https://example.com/lib-1.3.0.tar.gz": 0,
+		"https://example.com/lib.tar.gz":       0,
+	}
+
+	for url, expected := range cases {
+		ctx := &Ctx{Logger: NewLogger(), Config: &Config{}, LockFile: NewLockFile()}
+		ctx.Imports = []*Dependency{{Name: "example.com/lib", Archive: url}}
+		ctx.LockFile.Imports = []*Lock{{Name: "example.com/lib", Archive: url, Reversion: sha256Hex([]byte(url))}}
+
+		findings := ctx.MatchAdvisories([]*Advisory{adv})
+		if len(findings) != expected {
+			t.Errorf("%s: %d findings, expected %d", url, len(findings), expected)
+			continue
+		}
+
+		if expected > 0 && (findings[0].Version != "1.2.3" || findings[0].Fixed != "1.3.0") {
+			t.Errorf("%s: finding %+v", url, findings[0])
+		}
+	}
+}
+
+func TestAdvisorySeverity(t *testing.T) {
+	cases := map[string]string{
+		`{"database_specific":{"severity":"moderate"}}`:                                            "MEDIUM",
+		`{"severity":[{"type":"CVSS_V3","score":"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}]}`: "CRITICAL",
+		`{"database_specific":{"severity":"unknown"}}`:                                             "UNKNOWN",
+		`{"severity":[{"type":"CVSS_V3","score":"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N"}]}`: "NONE",
+		`{}`: "UNKNOWN",
+	}
+
+	for data, expected := range cases {
+		adv := &Advisory{}
+		if err := json.Unmarshal([]byte(data), adv); err != nil {
+			t.Fatal(err)
+		}
+
+		if level := adv.Severity(); level != expected {
+			t.Errorf("%s: %s, expected %s", data, level, expected)
+		}
+
+		if expected == "UNKNOWN" && SeverityRank(adv.Severity()) != 0 {
+			t.Errorf("%s: unknown severity is ranked", data)
+		}
+	}
+}
+
+func TestSeverityRank(t *testing.T) {
+	for i, level := range []string{"none", "LOW", "medium", "HIGH", "Critical"} {
+		if rank := SeverityRank(level); rank != i+1 {
+			t.Errorf("SeverityRank(%s) = %d, expected %d", level, rank, i+1)
+		}
+	}
+
+	for _, level := range []string{"", "UNKNOWN", "moderate"} {
+		if rank := SeverityRank(level); rank != 0 {
+			t.Errorf("SeverityRank(%s) = %d, expected 0", level, rank)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package gpm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DistDir is the dir of build artifacts, layout is dist/<target>/<goos>_<goarch>/<output>
+const DistDir = "dist"
+
+// Target is a named build target in build section of gpm.yaml
+type Target struct {
+	Name      string   `yaml:"name"`
+	Main      string   `yaml:"main,omitempty"`   // main package, default is .
+	Output    string   `yaml:"output,omitempty"` // binary name, default is name
+	Tags      []string `yaml:"tags,omitempty"`
+	Ldflags   string   `yaml:"ldflags,omitempty"`
+	Gcflags   string   `yaml:"gcflags,omitempty"`
+	Cgo       *bool    `yaml:"cgo,omitempty"`       // CGO_ENABLED, default of go if not set
+	Platforms []string `yaml:"platforms,omitempty"` // goos/goarch matrix, eg: linux/amd64
+}
+
+// Artifact is the result of building a target for one platform
+type Artifact struct {
+	Target   *Target
+	Platform string // goos/goarch
+	Path     string // relative to project root
+	Duration time.Duration
+	Cached   bool // restored from build cache, go build is skipped
+	ExitCode int  // exit status of go build
+	Err      error
+}
+
+// FindTarget returns build target by name, nil if not exist
+func (cfg *Config) FindTarget(name string) *Target {
+	for _, t := range cfg.Targets {
+		if t.Name == name {
+			return t
+		}
+	}
+
+	return nil
+}
+
+// HostPlatform returns goos/goarch of go env
+func HostPlatform() string {
+	out, err := exec.Command("go", "env", "GOOS", "GOARCH").Output()
+	fields := strings.Fields(string(out))
+	if err != nil || len(fields) != 2 {
+		return runtime.GOOS + "/" + runtime.GOARCH
+	}
+
+	return fields[0] + "/" + fields[1]
+}
+
+// splitPlatform split goos/goarch
+func splitPlatform(platform string) (string, string) {
+	tokens := strings.SplitN(platform, "/", 2)
+	if len(tokens) != 2 {
+		return platform, ""
+	}
+
+	return tokens[0], tokens[1]
+}
+
+// ArtifactPath returns dist/<target>/<goos>_<goarch>/<output>, .exe is added for windows
+func (t *Target) ArtifactPath(platform string) string {
+	goos, goarch := splitPlatform(platform)
+	name := t.Output
+	if name == "" {
+		name = t.Name
+	}
+
+	if goos == "windows" && !strings.HasSuffix(name, ".exe") {
+		name += ".exe"
+	}
+
+	return filepath.Join(DistDir, t.Name, goos+"_"+goarch, name)
+}
+
+// Args returns arguments of go build for output
+func (t *Target) Args(output string) []string {
+	args := []string{"build", "-o", output}
+	if len(t.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(t.Tags, ","))
+	}
+
+	if t.Ldflags != "" {
+		args = append(args, "-ldflags", t.Ldflags)
+	}
+
+	if t.Gcflags != "" {
+		args = append(args, "-gcflags", t.Gcflags)
+	}
+
+	main := t.Main
+	if main == "" {
+		main = "."
+	}
+
+	// local dir needs ./ prefix, otherwise it is an import path
+	if !filepath.IsAbs(main) && !strings.HasPrefix(main, ".") && Exists(main) {
+		main = "./" + main
+	}
+
+	return append(args, main)
+}
+
+// Env returns environment of go build for platform
+func (t *Target) Env(platform string) []string {
+	goos, goarch := splitPlatform(platform)
+	env := []string{"GOOS=" + goos, "GOARCH=" + goarch}
+	if t.Cgo != nil {
+		if *t.Cgo {
+			env = append(env, "CGO_ENABLED=1")
+		} else {
+			env = append(env, "CGO_ENABLED=0")
+		}
+	}
+
+	return env
+}
+
+// BuildArtifacts build targets for each of their platforms in parallel,
+// go build runs in dir with env, see GoEnv. artifacts are restored from
+// build cache if sources, gpm.lock, settings, stamp and go version are not changed,
+// version info is injected only when a build runs
+func (ctx *Ctx) BuildArtifacts(targets []*Target, platforms func(t *Target) []string, dir string, env []string, opts *BuildOptions) []*Artifact {
+	stamp := ctx.newStamper()
+	exclude := []string{}
+	if stamp != nil && stamp.file != "" {
+		exclude = append(exclude, stamp.file)
+	}
+
+	// inputs shared by all artifacts, the generated stamp file is not a source
+	sources, err := HashSources(ctx.WorkDir, exclude...)
+	if err != nil {
+		ctx.Warn("hash sources fail, build cache is disabled:%+v", err)
+		opts = &BuildOptions{Force: true, Explain: opts.Explain}
+	}
+
+	lock := ""
+	if Exists(LockName) {
+		lock, _ = LockHash()
+	}
+	goVersion := GoVersion(env)
+
+	artifacts := []*Artifact{}
+	for _, t := range targets {
+		for _, platform := range platforms(t) {
+			artifacts = append(artifacts, &Artifact{Target: t, Platform: platform, Path: t.ArtifactPath(platform)})
+		}
+	}
+
+	jobs := ctx.Settings.Jobs
+	if jobs <= 0 {
+		jobs = 1
+	}
+
+	queue := make(chan *Artifact)
+	wg := sync.WaitGroup{}
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for a := range queue {
+				in := &BuildInputs{Go: goVersion, Lock: lock, Settings: targetSettings(a.Target, a.Platform, env), Sources: sources}
+				for key, value := range stamp.settings() {
+					in.Settings[key] = value
+				}
+				ctx.cachedBuild(a, in, opts, dir, env, stamp)
+			}
+		}()
+	}
+
+	for _, a := range artifacts {
+		queue <- a
+	}
+	close(queue)
+	wg.Wait()
+
+	return artifacts
+}
+
+// cachedBuild restore artifact from cache, or build and store it
+func (ctx *Ctx) cachedBuild(a *Artifact, in *BuildInputs, opts *BuildOptions, dir string, env []string, stamp *stamper) {
+	key := in.Key()
+	manifest := manifestPath(a)
+	if !opts.Force && ctx.restoreArtifact(a, key) {
+		a.Cached = true
+		if opts.Explain {
+			ctx.Info("--> Cached %s %s", a.Target.Name, a.Platform)
+		}
+		saveManifest(manifest, in)
+		return
+	}
+
+	if opts.Explain {
+		reasons := in.Explain(loadManifest(manifest))
+		if opts.Force {
+			reasons = []string{"--force"}
+		} else if len(reasons) == 0 {
+			reasons = []string{"artifact not in cache"}
+		}
+
+		for _, reason := range reasons {
+			ctx.Info("--> Rebuild %s %s: %s", a.Target.Name, a.Platform, reason)
+		}
+	}
+
+	target, err := stamp.target(a.Target)
+	if err != nil {
+		a.Err, a.ExitCode = fmt.Errorf("stamp version fail:%+v", err), 1
+		return
+	}
+
+	ctx.buildArtifact(a, target, dir, env)
+	if a.Err != nil {
+		return
+	}
+
+	if err := ctx.storeArtifact(a, key); err != nil {
+		ctx.Warn("cache %s fail:%+v", a.Path, err)
+		return
+	}
+	saveManifest(manifest, in)
+}
+
+// buildArtifact build a with target, which is a.Target with version info
+func (ctx *Ctx) buildArtifact(a *Artifact, target *Target, dir string, env []string) {
+	ctx.Info("--> Build %s %s", a.Target.Name, a.Platform)
+	start := time.Now()
+	output := filepath.Join(ctx.WorkDir, a.Path)
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		a.Err = err
+		return
+	}
+
+	// env is shared by jobs
+	env = append([]string{}, env...)
+	for _, kv := range a.Target.Env(a.Platform) {
+		tokens := strings.SplitN(kv, "=", 2)
+		env = setEnv(env, tokens[0], tokens[1])
+	}
+
+	// output of parallel jobs is prefixed by line
+	out := &lineWriter{prefix: fmt.Sprintf("[%s %s] ", a.Target.Name, a.Platform), mu: &ctx.Logger.Mutex, w: os.Stderr}
+	defer out.Flush()
+
+	cmd := exec.Command("go", target.Args(output)...)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = out
+	cmd.Stderr = out
+	a.Err = cmd.Run()
+	a.Duration = time.Since(start)
+	if ee, ok := a.Err.(*exec.ExitError); ok {
+		a.ExitCode = ee.ExitCode()
+	} else if a.Err != nil {
+		a.ExitCode = 1
+	}
+}
+
+// lineWriter write complete lines with prefix, lines of writers sharing mu are not mixed
+type lineWriter struct {
+	prefix string
+	mu     *sync.Mutex
+	w      io.Writer
+	buf    []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i == -1 {
+			return len(p), nil
+		}
+
+		lw.writeLine(lw.buf[:i+1])
+		lw.buf = lw.buf[i+1:]
+	}
+}
+
+// Flush write the last line without line feed
+func (lw *lineWriter) Flush() {
+	if len(lw.buf) > 0 {
+		lw.writeLine(append(lw.buf, '\n'))
+		lw.buf = nil
+	}
+}
+
+func (lw *lineWriter) writeLine(line []byte) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	fmt.Fprintf(lw.w, "%s%s", lw.prefix, line)
+}
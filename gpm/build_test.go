@@ -0,0 +1,92 @@
+package gpm
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFindTarget(t *testing.T) {
+	cfg := &Config{Targets: []*Target{{Name: "app"}, {Name: "tool", Main: "cmd/tool"}}}
+	if target := cfg.FindTarget("tool"); target == nil || target.Main != "cmd/tool" {
+		t.Errorf("FindTarget(tool) = %+v", target)
+	}
+
+	if target := cfg.FindTarget("none"); target != nil {
+		t.Errorf("FindTarget(none) = %+v, expected nil", target)
+	}
+}
+
+func TestArtifactPath(t *testing.T) {
+	cases := []struct {
+		target   Target
+		platform string
+		expected string
+	}{
+		{Target{Name: "app"}, "linux/amd64", "dist/app/linux_amd64/app"},
+		{Target{Name: "app", Output: "server"}, "darwin/arm64", "dist/app/darwin_arm64/server"},
+		{Target{Name: "app"}, "windows/386", "dist/app/windows_386/app.exe"},
+		{Target{Name: "app", Output: "app.exe"}, "windows/amd64", "dist/app/windows_amd64/app.exe"},
+	}
+
+	for _, tc := range cases {
+		if actual := tc.target.ArtifactPath(tc.platform); actual != filepath.FromSlash(tc.expected) {
+			t.Errorf("ArtifactPath(%s) = %s, expected %s", tc.platform, actual, tc.expected)
+		}
+	}
+}
+
+func TestTargetArgs(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+	writeTree(t, dir, map[string]string{"cmd/tool/main.go": "package main\n"})
+
+	off := false
+	cases := []struct {
+		target Target
+		args   string
+		env    string
+	}{
+		{Target{Name: "app"}, "build -o out .", "GOOS=linux GOARCH=amd64"},
+		{Target{Name: "app", Main: "cmd/tool", Tags: []string{"a", "b"}}, "build -o out -tags a,b ./cmd/tool", "GOOS=linux GOARCH=amd64"},
+		{Target{Name: "app", Main: "github.com/a/b/cmd", Ldflags: "-s -w", Gcflags: "-N"}, "build -o out -ldflags -s -w -gcflags -N github.com/a/b/cmd", "GOOS=linux GOARCH=amd64"},
+		{Target{Name: "app", Main: "./cmd/tool", Cgo: &off}, "build -o out ./cmd/tool", "GOOS=linux GOARCH=amd64 CGO_ENABLED=0"},
+	}
+
+	for _, tc := range cases {
+		if args := strings.Join(tc.target.Args("out"), " "); args != tc.args {
+			t.Errorf("Args(%+v) = %s, expected %s", tc.target, args, tc.args)
+		}
+
+		if env := strings.Join(tc.target.Env("linux/amd64"), " "); env != tc.env {
+			t.Errorf("Env(%+v) = %s, expected %s", tc.target, env, tc.env)
+		}
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	out := &bytes.Buffer{}
+	mu := &sync.Mutex{}
+	a := &lineWriter{prefix: "[a] ", mu: mu, w: out}
+	b := &lineWriter{prefix: "[b] ", mu: mu, w: out}
+
+	a.Write([]byte("one "))
+	b.Write([]byte("x\ny"))
+	a.Write([]byte("line\ntwo"))
+	a.Flush()
+	b.Flush()
+	b.Flush()
+
+	expected := []string{"[b] x", "[a] one line", "[a] two", "[b] y", ""}
+	if lines := strings.Split(out.String(), "\n"); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("output %q, expected %q", lines, expected)
+	}
+}
@@ -0,0 +1,272 @@
+package gpm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// BuildManifestDir keeps inputs of the last build of each artifact, used by --explain
+const BuildManifestDir = ".gpm/build"
+
+// sourceExts are files which affect go build, test files are not
+var sourceExts = []string{".go", ".s", ".c", ".h", ".cc", ".cpp", ".cxx", ".hh", ".hpp", ".hxx", ".m", ".f", ".swig", ".swigcxx", ".syso"}
+
+// buildEnvPrefixes are environment variables which affect go build
+var buildEnvPrefixes = []string{"GO", "CGO_", "CC=", "CXX=", "AR=", "PKG_CONFIG"}
+
+// BuildOptions control the build cache
+type BuildOptions struct {
+	Force   bool // build even if cached
+	Explain bool // print why rebuild happened
+}
+
+// BuildInputs are inputs of a build, key of build cache is the hash of them
+type BuildInputs struct {
+	Go       string            `yaml:"go"`       // go version
+	Lock     string            `yaml:"lock"`     // sha256 of gpm.lock
+	Settings map[string]string `yaml:"settings"` // target, platform and environment
+	Sources  map[string]string `yaml:"sources"`  // path -> sha256 of sources, vendor excluded
+}
+
+// Key returns hash of inputs
+func (in *BuildInputs) Key() string {
+	// maps are sorted by yaml
+	data, _ := yaml.Marshal(in)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Explain returns changes of inputs since old
+func (in *BuildInputs) Explain(old *BuildInputs) []string {
+	if old == nil {
+		return []string{"no previous build"}
+	}
+
+	reasons := []string{}
+	if in.Go != old.Go {
+		reasons = append(reasons, fmt.Sprintf("go version changed: %s => %s", old.Go, in.Go))
+	}
+
+	if in.Lock != old.Lock {
+		reasons = append(reasons, LockName+" changed")
+	}
+
+	for _, key := range diffKeys(old.Settings, in.Settings) {
+		reasons = append(reasons, fmt.Sprintf("%s changed: %q => %q", key, old.Settings[key], in.Settings[key]))
+	}
+
+	for _, path := range diffKeys(old.Sources, in.Sources) {
+		switch {
+		case old.Sources[path] == "":
+			reasons = append(reasons, path+" added")
+		case in.Sources[path] == "":
+			reasons = append(reasons, path+" removed")
+		default:
+			reasons = append(reasons, path+" modified")
+		}
+	}
+
+	return reasons
+}
+
+// diffKeys returns sorted keys which values are different
+func diffKeys(a map[string]string, b map[string]string) []string {
+	keys := []string{}
+	for key, value := range a {
+		if b[key] != value {
+			keys = append(keys, key)
+		}
+	}
+
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// HashSources returns sha256 of source files in dir, vendor, dist, hidden
+// dirs and dirs ignored by go are skipped, exclude are files relative to dir
+func HashSources(dir string, exclude ...string) (map[string]string, error) {
+	result := make(map[string]string)
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name := fi.Name()
+		if fi.IsDir() {
+			if path != dir && (name == "vendor" || name == DistDir || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !fi.Mode().IsRegular() || !isSource(name) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range exclude {
+			if filepath.Clean(file) == rel {
+				return nil
+			}
+		}
+
+		sum, err := HashFile(path)
+		if err != nil {
+			return err
+		}
+
+		result[filepath.ToSlash(rel)] = sum
+		return nil
+	})
+
+	return result, err
+}
+
+func isSource(name string) bool {
+	if name == "go.mod" || name == "go.sum" {
+		return true
+	}
+
+	if strings.HasSuffix(name, "_test.go") {
+		return false
+	}
+
+	return hasString(sourceExts, filepath.Ext(name))
+}
+
+// GoVersion returns output of go version
+func GoVersion(env []string) string {
+	cmd := exec.Command("go", "version")
+	cmd.Env = env
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
+
+// targetSettings returns settings of target and environment which affect go build
+func targetSettings(t *Target, platform string, env []string) map[string]string {
+	settings := map[string]string{
+		"main":     t.Main,
+		"output":   t.Output,
+		"tags":     strings.Join(t.Tags, ","),
+		"ldflags":  t.Ldflags,
+		"gcflags":  t.Gcflags,
+		"platform": platform,
+	}
+
+	if t.Cgo != nil {
+		settings["cgo"] = strconv.FormatBool(*t.Cgo)
+	}
+
+	for _, kv := range env {
+		for _, prefix := range buildEnvPrefixes {
+			if strings.HasPrefix(kv, prefix) {
+				tokens := strings.SplitN(kv, "=", 2)
+				settings["env."+tokens[0]] = tokens[1]
+				break
+			}
+		}
+	}
+
+	return settings
+}
+
+// manifestPath returns .gpm/build/<target>/<goos>_<goarch>.yaml
+func manifestPath(a *Artifact) string {
+	goos, goarch := splitPlatform(a.Platform)
+	return filepath.Join(BuildManifestDir, a.Target.Name, goos+"_"+goarch+".yaml")
+}
+
+func loadManifest(file string) *BuildInputs {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	in := &BuildInputs{}
+	if err := yaml.Unmarshal(data, in); err != nil {
+		return nil
+	}
+
+	return in
+}
+
+func saveManifest(file string, in *BuildInputs) error {
+	data, err := yaml.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, data, 0644)
+}
+
+// cachePath returns <cache>/build/<key>/<output>
+func (ctx *Ctx) cachePath(a *Artifact, key string) string {
+	return filepath.Join(ctx.CacheDir, "build", key, filepath.Base(a.Path))
+}
+
+// restoreArtifact copy cached artifact to dist, returns false if not cached
+func (ctx *Ctx) restoreArtifact(a *Artifact, key string) bool {
+	cached := ctx.cachePath(a, key)
+	fi, err := os.Stat(cached)
+	if err != nil {
+		return false
+	}
+
+	output := filepath.Join(ctx.WorkDir, a.Path)
+	if old, err := HashFile(output); err == nil {
+		if sum, err := HashFile(cached); err == nil && old == sum {
+			return true
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0755); err != nil {
+		return false
+	}
+
+	return CopyFile(cached, output, fi.Mode()) == nil
+}
+
+// storeArtifact copy built artifact into cache
+func (ctx *Ctx) storeArtifact(a *Artifact, key string) error {
+	output := filepath.Join(ctx.WorkDir, a.Path)
+	fi, err := os.Stat(output)
+	if err != nil {
+		return err
+	}
+
+	cached := ctx.cachePath(a, key)
+	if err := os.MkdirAll(filepath.Dir(cached), 0755); err != nil {
+		return err
+	}
+
+	return CopyFile(output, cached, fi.Mode())
+}
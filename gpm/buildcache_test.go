@@ -0,0 +1,130 @@
+package gpm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestHashSources(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	writeTree(t, dir, map[string]string{
+		"main.go":             "package main\n",
+		"main_test.go":        "package main\n",
+		"go.mod":              "module app\n",
+		"asm_amd64.s":         "TEXT\n",
+		"README.md":           "readme",
+		"gpm.yaml":            "package: app\n",
+		"version.go":          "package main\n",
+		"pkg/a/a.go":          "package a\n",
+		"pkg/a/testdata/x.go": "package x\n",
+		"vendor/b/b.go":       "package b\n",
+		"dist/app/main.go":    "package main\n",
+		".git/hooks/a.go":     "package a\n",
+		"_old/old.go":         "package old\n",
+	})
+
+	sums, err := HashSources(dir, "./version.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{}
+	for key := range sums {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	expected := []string{"asm_amd64.s", "go.mod", "main.go", "pkg/a/a.go"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("HashSources = %v, expected %v", keys, expected)
+	}
+
+	if sums["main.go"] != sha256Hex([]byte("package main\n")) {
+		t.Errorf("hash of main.go %s", sums["main.go"])
+	}
+}
+
+func TestBuildInputsExplain(t *testing.T) {
+	old := &BuildInputs{
+		Go:       "go1.12",
+		Lock:     "a",
+		Settings: map[string]string{"platform": "linux/amd64", "tags": ""},
+		Sources:  map[string]string{"a.go": "1", "b.go": "2", "c.go": "3"},
+	}
+
+	cases := []struct {
+		in       BuildInputs
+		expected []string
+	}{
+		{*old, []string{}},
+		{BuildInputs{Go: "go1.13", Lock: "b", Settings: old.Settings, Sources: old.Sources}, []string{"go version changed: go1.12 => go1.13", LockName + " changed"}},
+		{BuildInputs{Go: "go1.12", Lock: "a", Settings: map[string]string{"platform": "linux/amd64", "tags": "a", "cgo": "false"}, Sources: old.Sources}, []string{`cgo changed: "" => "false"`, `tags changed: "" => "a"`}},
+		{BuildInputs{Go: "go1.12", Lock: "a", Settings: old.Settings, Sources: map[string]string{"a.go": "1", "b.go": "x", "d.go": "4"}}, []string{"b.go modified", "c.go removed", "d.go added"}},
+	}
+
+	for i, tc := range cases {
+		if actual := tc.in.Explain(old); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("case %d: Explain() = %q, expected %q", i, actual, tc.expected)
+		}
+
+		if (tc.in.Key() == old.Key()) != (len(tc.expected) == 0) {
+			t.Errorf("case %d: key changed %v, expected %v", i, tc.in.Key() != old.Key(), len(tc.expected) != 0)
+		}
+	}
+
+	if actual := old.Explain(nil); !reflect.DeepEqual(actual, []string{"no previous build"}) {
+		t.Errorf("Explain(nil) = %q", actual)
+	}
+}
+
+func TestTargetSettings(t *testing.T) {
+	on := true
+	target := &Target{Name: "app", Main: "./cmd/app", Tags: []string{"a", "b"}, Cgo: &on}
+	env := []string{"PATH=/bin", "GOFLAGS=-mod=vendor", "CGO_CFLAGS=-O2", "CC=clang", "CCACHE=1", "HOME=/root", "PKG_CONFIG_PATH=/lib"}
+	expected := map[string]string{
+		"main":                "./cmd/app",
+		"output":              "",
+		"tags":                "a,b",
+		"ldflags":             "",
+		"gcflags":             "",
+		"platform":            "linux/arm64",
+		"cgo":                 "true",
+		"env.GOFLAGS":         "-mod=vendor",
+		"env.CGO_CFLAGS":      "-O2",
+		"env.CC":              "clang",
+		"env.PKG_CONFIG_PATH": "/lib",
+	}
+
+	if actual := targetSettings(target, "linux/arm64", env); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("targetSettings() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestManifest(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	a := &Artifact{Target: &Target{Name: "app"}, Platform: "linux/amd64"}
+	if path := manifestPath(a); path != filepath.Join(BuildManifestDir, "app", "linux_amd64.yaml") {
+		t.Errorf("manifestPath() = %s", path)
+	}
+
+	in := &BuildInputs{Go: "go1.12", Lock: "a", Settings: map[string]string{"tags": "x"}, Sources: map[string]string{"a.go": "1"}}
+	file := filepath.Join(dir, manifestPath(a))
+	if loadManifest(file) != nil {
+		t.Error("manifest loaded before saved")
+	}
+
+	if err := saveManifest(file, in); err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded := loadManifest(file); loaded == nil || loaded.Key() != in.Key() {
+		t.Errorf("loaded %+v, expected %+v", loaded, in)
+	}
+}
@@ -0,0 +1,377 @@
+package gpm
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// BundleManifestName is the manifest file name in bundle
+const BundleManifestName = "gpm-bundle.yaml"
+
+// BundleEntry is a locked dependency packed in bundle
+type BundleEntry struct {
+	Name      string `yaml:"name"`
+	Reversion string `yaml:"reversion"`
+	Path      string `yaml:"path"` // cache path relative to cache dir
+}
+
+// BundleManifest describes the content of a bundle
+type BundleManifest struct {
+	Lock    string            `yaml:"lock"` // sha256 of gpm.lock
+	Entries []*BundleEntry    `yaml:"entries"`
+	Files   map[string]string `yaml:"files"` // sha256 of every regular file
+}
+
+// ExportBundle pack cache of all locked dependencies into a tar.gz file
+func (ctx *Ctx) ExportBundle(file string) error {
+	lockHash, err := LockHash()
+	if err != nil {
+		return fmt.Errorf("read lock fail, run gpm install first:%+v", err)
+	}
+
+	manifest := &BundleManifest{Lock: lockHash, Files: make(map[string]string)}
+	for _, dep := range ctx.Imports {
+		lock := ctx.LockFile.Find(dep.Name)
+		if lock == nil {
+			return fmt.Errorf("dependency not locked:%+v", dep.Name)
+		}
+
+		if r := ctx.FindReplace(dep.Name); r != nil && r.IsLocal() {
+			ctx.Warn("skip local replace:%+v", dep.Name)
+			continue
+		}
+
+		local := ""
+		if dep.IsArchive() && lock.Archive != "" {
+			local = ctx.ArchivePath(dep)
+		} else if local, err = ctx.CacheLocal(ctx.RemoteOf(dep)); err != nil {
+			return err
+		}
+
+		if !Exists(local) {
+			return fmt.Errorf("dependency not in cache:%+v", dep.Name)
+		}
+
+		rel, err := filepath.Rel(ctx.CacheDir, local)
+		if err != nil {
+			return err
+		}
+
+		entry := &BundleEntry{Name: dep.Name, Reversion: lock.Reversion, Path: filepath.ToSlash(rel)}
+		manifest.Entries = append(manifest.Entries, entry)
+	}
+
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+	for _, entry := range manifest.Entries {
+		ctx.Info("--> Bundle %s", entry.Name)
+		if err := tarDir(tw, ctx.CacheDir, entry.Path, manifest.Files); err != nil {
+			return err
+		}
+	}
+
+	data, err := yaml.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	hdr := &tar.Header{Name: BundleManifestName, Mode: 0644, Size: int64(len(data))}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if err := gw.Close(); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// ImportBundle unpack bundle into cache dir, bundle must match the current gpm.lock
+func (ctx *Ctx) ImportBundle(file string, cacheDir string) error {
+	lockHash, err := LockHash()
+	if err != nil {
+		return fmt.Errorf("read lock fail:%+v", err)
+	}
+
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+
+	// verify the whole bundle before anything is written
+	manifest, sums, err := readBundle(file)
+	if err != nil {
+		return err
+	}
+
+	if manifest.Lock != lockHash {
+		return fmt.Errorf("bundle was created for another gpm.lock: expected %s, got %s", lockHash, manifest.Lock)
+	}
+
+	if err := checkBundleFiles(manifest.Files, sums); err != nil {
+		return err
+	}
+
+	for _, entry := range manifest.Entries {
+		if !validBundlePath(entry.Path) {
+			return fmt.Errorf("invalid bundle entry path:%+v", entry.Path)
+		}
+	}
+
+	// unpack into the same filesystem so entries can be renamed into place
+	tmp, err := ioutil.TempDir(cacheDir, ".bundle-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	// files are checked again, bundle may be changed after verified
+	if sums, err = untarBundle(file, tmp); err != nil {
+		return err
+	}
+
+	if err := checkBundleFiles(manifest.Files, sums); err != nil {
+		return err
+	}
+
+	for _, entry := range manifest.Entries {
+		src := filepath.Join(tmp, filepath.FromSlash(entry.Path))
+		dst := filepath.Join(cacheDir, filepath.FromSlash(entry.Path))
+		ctx.Info("--> Import %s, %s", entry.Name, dst)
+		if err := os.RemoveAll(dst); err != nil {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkBundleFiles compare checksums in manifest with unpacked files
+func checkBundleFiles(expected map[string]string, actual map[string]string) error {
+	names := []string{}
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sum, ok := actual[name]
+		if !ok {
+			return fmt.Errorf("bundle file missing:%+v", name)
+		}
+
+		if sum != expected[name] {
+			return fmt.Errorf("bundle checksum mismatch:%+v", name)
+		}
+	}
+
+	for name := range actual {
+		if _, ok := expected[name]; !ok {
+			return fmt.Errorf("bundle file not in manifest:%+v", name)
+		}
+	}
+
+	return nil
+}
+
+// tarDir write root/rel into tar, record sha256 of regular files
+func tarDir(tw *tar.Writer, root string, rel string, sums map[string]string) error {
+	return filepath.Walk(filepath.Join(root, filepath.FromSlash(rel)), func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+
+		link := ""
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = name
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(tw, h), f); err != nil {
+			return err
+		}
+
+		sums[name] = hex.EncodeToString(h.Sum(nil))
+		return nil
+	})
+}
+
+// readBundle returns manifest and sha256 of regular files in bundle, nothing is unpacked
+func readBundle(file string) (*BundleManifest, map[string]string, error) {
+	var manifest *BundleManifest
+	sums := make(map[string]string)
+	err := walkBundle(file, func(name string, hdr *tar.Header, r io.Reader) error {
+		if name == BundleManifestName {
+			data, err := ioutil.ReadAll(r)
+			if err != nil {
+				return err
+			}
+
+			manifest = &BundleManifest{}
+			return yaml.Unmarshal(data, manifest)
+		}
+
+		if hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA {
+			h := sha256.New()
+			if _, err := io.Copy(h, r); err != nil {
+				return err
+			}
+			sums[name] = hex.EncodeToString(h.Sum(nil))
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if manifest == nil {
+		return nil, nil, fmt.Errorf("bundle manifest not found:%+v", BundleManifestName)
+	}
+
+	return manifest, sums, nil
+}
+
+// untarBundle unpack bundle to dir, returns sha256 of regular files.
+// symlinks must stay in dir, entries are never written through a symlink
+func untarBundle(file string, dir string) (map[string]string, error) {
+	sums := make(map[string]string)
+	err := walkBundle(file, func(name string, hdr *tar.Header, r io.Reader) error {
+		if name == BundleManifestName {
+			return nil
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		if err := checkUnpackPath(dir, target); err != nil {
+			return err
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			return os.MkdirAll(target, 0755)
+		case tar.TypeSymlink:
+			return writeSymlink(dir, target, hdr.Linkname)
+		case tar.TypeReg, tar.TypeRegA:
+			h := sha256.New()
+			err := writeFile(target, io.TeeReader(r, h), os.FileMode(hdr.Mode))
+			sums[name] = hex.EncodeToString(h.Sum(nil))
+			return err
+		}
+
+		return fmt.Errorf("unsupported bundle entry:%+v", name)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sums, nil
+}
+
+// walkBundle call fn with cleaned name of each entry in bundle
+func walkBundle(file string, fn func(name string, hdr *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+		if name == "" {
+			continue
+		}
+
+		if err := fn(name, hdr, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// validBundlePath returns true if path is relative and stays in cache dir
+func validBundlePath(p string) bool {
+	return p != "" && !path.IsAbs(p) && !filepath.IsAbs(p) && path.Clean("/" + p)[1:] == p
+}
@@ -0,0 +1,150 @@
+package gpm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBundle(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(root)
+
+	archive := "https://example.com/lib-1.0.0.tar.gz"
+	sha := sha256Hex([]byte(archive))
+	ctx := &Ctx{Logger: NewLogger(), Config: &Config{}, LockFile: NewLockFile(), Settings: DefaultSettings(), CacheDir: filepath.Join(root, "cache")}
+	ctx.Quiet = true
+	ctx.Imports = []*Dependency{{Name: "github.com/pkg/errors"}, {Name: "example.com/lib", Archive: archive, Sha256: sha}, {Name: "example.com/local"}}
+	ctx.Replaces = []*Replace{{Name: "example.com/local", Path: "../local"}}
+	ctx.LockFile.Imports = []*Lock{{Name: "github.com/pkg/errors", Reversion: "abc"}, {Name: "example.com/lib", Archive: archive, Reversion: sha}, {Name: "example.com/local", Replace: "../local"}}
+	if err := ctx.LockFile.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := ctx.CacheLocal(ctx.RemoteOf(ctx.Imports[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, repo, map[string]string{"errors.go": "package errors\n", ".git/HEAD": "ref\n"})
+	writeTree(t, filepath.Dir(ctx.ArchivePath(ctx.Imports[1])), map[string]string{filepath.Base(ctx.ArchivePath(ctx.Imports[1])): "archive"})
+
+	file := filepath.Join(root, "deps.tar.gz")
+	if err := ctx.ExportBundle(file); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := filepath.Join(root, "imported")
+	if err := ctx.ImportBundle(file, cache); err != nil {
+		t.Fatal(err)
+	}
+
+	rel, _ := filepath.Rel(ctx.CacheDir, repo)
+	if readString(t, filepath.Join(cache, rel, "errors.go")) != "package errors\n" || !Exists(filepath.Join(cache, rel, ".git", "HEAD")) {
+		t.Error("repo is not imported")
+	}
+
+	rel, _ = filepath.Rel(ctx.CacheDir, ctx.ArchivePath(ctx.Imports[1]))
+	if readString(t, filepath.Join(cache, rel)) != "archive" {
+		t.Error("archive is not imported")
+	}
+
+	// bundle of another lock
+	ctx.LockFile.Set(&Lock{Name: "github.com/pkg/errors", Reversion: "def"})
+	ctx.LockFile.Save()
+	if err := ctx.ImportBundle(file, cache); err == nil || !strings.Contains(err.Error(), "another gpm.lock") {
+		t.Errorf("bundle of another lock is imported:%+v", err)
+	}
+}
+
+func TestImportTamperedBundle(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(root)
+
+	ioutil.WriteFile(LockName, []byte("imports: []\n"), 0644)
+	lock, _ := LockHash()
+	manifest := "lock: " + lock + "\nentries:\n- name: example.com/a\n  path: a\nfiles:\n  a/a.go: " + sha256Hex([]byte("package a\n")) + "\n"
+
+	cases := []struct {
+		name    string
+		entries []fixtureEntry
+		err     string
+	}{
+		{"valid", []fixtureEntry{{Name: "a/a.go", Body: "package a\n"}}, ""},
+		{"changed", []fixtureEntry{{Name: "a/a.go", Body: "package b\n"}}, "checksum mismatch"},
+		{"missing", nil, "file missing"},
+		{"extra", []fixtureEntry{{Name: "a/a.go", Body: "package a\n"}, {Name: "a/b.go", Body: "package a\n"}}, "not in manifest"},
+		{"escape link", []fixtureEntry{{Name: "a/a.go", Body: "package a\n"}, {Name: "a/up", Link: "../.."}}, "unsafe symlink"},
+	}
+
+	for _, tc := range cases {
+		file := filepath.Join(root, "bundle.tar.gz")
+		entries := append(tc.entries, fixtureEntry{Name: BundleManifestName, Body: manifest})
+		if err := ioutil.WriteFile(file, tarGzFixture(t, entries), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cache := filepath.Join(root, "cache")
+		ctx := &Ctx{Logger: NewLogger()}
+		ctx.Quiet = true
+		err := ctx.ImportBundle(file, cache)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Errorf("%s: %+v", tc.name, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%s: expected %q, got %+v", tc.name, tc.err, err)
+		case tc.err != "" && Exists(filepath.Join(cache, "a")):
+			t.Errorf("%s: tampered bundle is unpacked", tc.name)
+		}
+
+		os.RemoveAll(cache)
+	}
+}
+
+func TestValidBundlePath(t *testing.T) {
+	cases := map[string]bool{
+		"github.com/pkg/errors":     true,
+		"archives/abc.tar.gz":       true,
+		"":                          false,
+		"/etc":                      false,
+		"../cache":                  false,
+		"a/../../b":                 false,
+		"a/./b":                     false,
+		"https-github.com-a-b/../x": false,
+	}
+
+	for p, expected := range cases {
+		if actual := validBundlePath(p); actual != expected {
+			t.Errorf("validBundlePath(%q) = %v", p, actual)
+		}
+	}
+}
+
+// gzip of an empty tar is not a bundle
+func TestReadBundleWithoutManifest(t *testing.T) {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tar.NewWriter(gw).Close()
+	gw.Close()
+
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	file := filepath.Join(root, "empty.tar.gz")
+	ioutil.WriteFile(file, buf.Bytes(), 0644)
+	if _, _, err := readBundle(file); err == nil || !strings.Contains(err.Error(), "manifest not found") {
+		t.Errorf("bundle without manifest:%+v", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 
 	yaml "gopkg.in/yaml.v2"
@@ -16,17 +17,6 @@ const (
 	LockName = "gpm.lock"
 )
 
-// Lock represents an individual locked dependency.
-type Lock struct {
-	Name      string
-	Reversion string
-}
-
-// LockFile represents a gpm.lock file.
-type LockFile struct {
-	Imports []*Lock
-}
-
 // Owner describes an owner of a package. This can be a person, company, or
 // other organization. This is useful if someone needs to contact the
 // owner of a package to address things like a security issue.
@@ -38,10 +28,23 @@ type Owner struct {
 
 // Dependency describes a package that the present package depends upon.
 type Dependency struct {
-	Name       string `yaml:"package"`
-	Version    string `yaml:"version,omitempty"` // semantic version
-	Repository string `yaml:"repo,omitempty"`    //
-	Reversion  string `yaml:"-"`                 // Version for lock
+	Name            string   `yaml:"package"`
+	Version         string   `yaml:"version,omitempty"`          // semantic version
+	Repository      string   `yaml:"repo,omitempty"`             //
+	Archive         string   `yaml:"archive,omitempty"`          // tar.gz or zip url, used instead of repo
+	Sha256          string   `yaml:"sha256,omitempty"`           // checksum of archive, required for archive
+	StripPrefix     string   `yaml:"strip-prefix,omitempty"`     // leading dir in archive to strip
+	Patches         []string `yaml:"patches,omitempty"`          // unified diff files, applied in order after export
+	VerifySignature bool     `yaml:"verify-signature,omitempty"` // tag must be signed by a trusted key
+	Exempt          []string `yaml:"exempt,omitempty"`           // policy rules not applied: hosts, banned, minimum
+	Justification   string   `yaml:"justification,omitempty"`    // why exempted rules do not apply, required by exempt
+	Reversion       string   `yaml:"-"`                          // Version for lock
+	Base            string   `yaml:"-"`                          // extends entry it is inherited from, empty if local
+}
+
+// IsArchive returns true if dependency is fetched from http archive
+func (d *Dependency) IsArchive() bool {
+	return d.Archive != ""
 }
 
 // Remote returns the remote location to fetch source from. This location is
@@ -58,15 +61,71 @@ func (d *Dependency) Remote() string {
 	return r
 }
 
+// Replace maps a package to a local directory or to a fork repository.
+type Replace struct {
+	Name       string `yaml:"package"`
+	Path       string `yaml:"path,omitempty"` // local directory
+	Repository string `yaml:"repo,omitempty"` // fork url
+}
+
+// IsLocal returns true if package is replaced by a local directory
+func (r *Replace) IsLocal() bool {
+	return r.Path != ""
+}
+
+// Target returns the local path or fork url
+func (r *Replace) Target() string {
+	if r.Path != "" {
+		return r.Path
+	}
+
+	return r.Repository
+}
+
+// LicensePolicy allow or deny licenses of dependencies by SPDX identifier,
+// unknown licenses are always disallowed
+type LicensePolicy struct {
+	Allow  []string `yaml:"allow,omitempty"`
+	Deny   []string `yaml:"deny,omitempty"`
+	Ignore []string `yaml:"ignore,omitempty"` // packages not checked
+}
+
+// AuditConfig configure gpm audit
+type AuditConfig struct {
+	DB       string `yaml:"db,omitempty"`       // dir or zip of OSV advisories
+	Severity string `yaml:"severity,omitempty"` // fail at or above this level, default is low
+	Unknown  string `yaml:"unknown,omitempty"`  // level of advisories without severity, default is high
+}
+
 // Config is the top-level configuration object.
 type Config struct {
-	Name    string        `yaml:"package"`
-	Version string        `yaml:"version"`
-	Home    string        `yaml:"home,omitempty"`
-	Desc    string        `yaml:"description,omitempty"`
-	License string        `yaml:"license,omitempty"`
-	Owners  []*Owner      `yaml:"owners,omitempty"`
-	Imports []*Dependency `yaml:"import"`
+	Name     string         `yaml:"package"`
+	Version  string         `yaml:"version"`
+	Go       string         `yaml:"go,omitempty"`      // constraint of go version, eg: >=1.10 <1.12
+	Extends  []string       `yaml:"extends,omitempty"` // base configs, local file or <package>//<file> without settings and replace
+	Home     string         `yaml:"home,omitempty"`
+	Desc     string         `yaml:"description,omitempty"`
+	License  string         `yaml:"license,omitempty"`
+	Owners   []*Owner       `yaml:"owners,omitempty"`
+	Imports  []*Dependency  `yaml:"import"`
+	Replaces []*Replace     `yaml:"replace,omitempty"`
+	Licenses *LicensePolicy `yaml:"licenses,omitempty"`
+	Audit    *AuditConfig   `yaml:"audit,omitempty"`
+	Policy   *Policy        `yaml:"policy,omitempty"`
+	Targets  []*Target      `yaml:"build,omitempty"` // build targets of gpm build
+	Stamp    *Stamp         `yaml:"stamp,omitempty"` // version info injected by gpm build
+	Tests    *TestConfig    `yaml:"test,omitempty"`
+	// environment of go commands, override inherited ones, eg: CGO_CFLAGS: -I${HOME}/include
+	Env map[string]string `yaml:"env,omitempty"`
+	// commands of gpm run, eg: lint: {run: golangci-lint run ./...}
+	Scripts map[string]*Script `yaml:"scripts,omitempty"`
+	// settings of project, override system and user settings
+	ProjectSettings *Settings `yaml:"settings,omitempty"`
+	source          []byte    // content loaded, edited in place by Save
+
+	local             *localSections         // sections before bases are merged
+	inherited         map[string]*Dependency // imports inherited from bases, as merged
+	inheritedReplaces map[string]*Replace    // replaces inherited from bases, as merged
 }
 
 // NewDependency create dependency
@@ -111,37 +170,50 @@ func (cfg *Config) Exist() bool {
 	return err == nil
 }
 
-// Load 加载配置文件
+// Load 加载配置文件,并合并extends中的基础配置
 func (cfg *Config) Load() error {
-	data, err := ioutil.ReadFile(ConfName)
+	loaded, err := readConfig(ConfName)
 	if err != nil {
 		return err
 	}
 
-	if err := yaml.Unmarshal(data, cfg); err != nil {
-		return err
+	*cfg = *loaded
+	if cfg.Policy != nil {
+		if err := cfg.Policy.LoadFile(); err != nil {
+			return err
+		}
 	}
 
-	// try fix name and version
-	for _, dep := range cfg.Imports {
-		if dep.Version == "" && strings.Contains(dep.Name, "@") {
-			tokens := strings.Split(dep.Name, "@")
-			dep.Name = tokens[0]
-			dep.Version = tokens[1]
-		}
+	file, err := filepath.Abs(ConfName)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return cfg.extend(filepath.Dir(file), map[string]bool{file: true})
 }
 
 // Save 保存配置文件
+// 已加载的配置只修改变化的部分,保留注释和顺序,继承的条目不会写入
 func (cfg *Config) Save() error {
-	data, err := yaml.Marshal(cfg)
+	var data []byte
+	var err error
+	view := cfg.saveView()
+	if cfg.source != nil {
+		data, err = EditYAML(cfg.source, view)
+	} else {
+		data, err = yaml.Marshal(view)
+	}
+
 	if err != nil {
 		return err
 	}
 
-	return ioutil.WriteFile(ConfName, data, 0666)
+	if err := ioutil.WriteFile(ConfName, data, 0666); err != nil {
+		return err
+	}
+
+	cfg.source = data
+	return nil
 }
 
 // func (cfg *Config) LoadLock() error {
@@ -154,6 +226,17 @@ func (cfg *Config) Save() error {
 // 	return yaml.Unmarshal(data, l)
 // }
 
+// FindDependency returns dependency by name, nil if not exist
+func (cfg *Config) FindDependency(name string) *Dependency {
+	for _, d := range cfg.Imports {
+		if d.Name == name {
+			return d
+		}
+	}
+
+	return nil
+}
+
 // HasDependency returns true if the given name is listed as an import or dev import.
 func (cfg *Config) HasDependency(name string) bool {
 	for _, d := range cfg.Imports {
@@ -184,6 +267,41 @@ func (cfg *Config) AddDependency(dep *Dependency) {
 	}
 }
 
+// FindReplace returns the replace of package, nil if not replaced
+func (cfg *Config) FindReplace(name string) *Replace {
+	for _, r := range cfg.Replaces {
+		if r.Name == name {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// SetReplace add or update replace
+func (cfg *Config) SetReplace(replace *Replace) {
+	for i, r := range cfg.Replaces {
+		if r.Name == replace.Name {
+			cfg.Replaces[i] = replace
+			return
+		}
+	}
+
+	cfg.Replaces = append(cfg.Replaces, replace)
+}
+
+// DelReplace delete replace
+func (cfg *Config) DelReplace(name string) bool {
+	for i, r := range cfg.Replaces {
+		if r.Name == name {
+			cfg.Replaces = append(cfg.Replaces[:i], cfg.Replaces[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
 // NewDependency create Dependency if not exist
 // func (cfg *Config) NewDependency(name string) (*Dependency, error) {
 // 	//
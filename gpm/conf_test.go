@@ -0,0 +1,163 @@
+package gpm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplace(t *testing.T) {
+	cfg := &Config{}
+	cfg.SetReplace(&Replace{Name: "example.com/a", Path: "../a"})
+	cfg.SetReplace(&Replace{Name: "example.com/b", Repository: "https://git.corp.com/b.git"})
+	cfg.SetReplace(&Replace{Name: "example.com/a", Path: "../a2"})
+
+	cases := []struct {
+		name   string
+		target string
+		local  bool
+	}{
+		{"example.com/a", "../a2", true},
+		{"example.com/b", "https://git.corp.com/b.git", false},
+	}
+
+	if len(cfg.Replaces) != len(cases) {
+		t.Fatalf("replaces: %+v", cfg.Replaces)
+	}
+
+	for _, tc := range cases {
+		r := cfg.FindReplace(tc.name)
+		if r == nil || r.Target() != tc.target || r.IsLocal() != tc.local {
+			t.Errorf("%s: %+v", tc.name, r)
+		}
+	}
+
+	if !cfg.DelReplace("example.com/a") || cfg.DelReplace("example.com/a") || cfg.FindReplace("example.com/a") != nil {
+		t.Errorf("replace is not deleted: %+v", cfg.Replaces)
+	}
+}
+
+func TestRemoteOf(t *testing.T) {
+	ctx := &Ctx{Config: &Config{}, Settings: DefaultSettings()}
+	ctx.Settings.Mirrors = map[string]string{"golang.org/x": "https://github.com/golang"}
+	ctx.Replaces = []*Replace{
+		{Name: "example.com/fork", Repository: "https://git.corp.com/fork.git"},
+		{Name: "example.com/local", Path: "../local"},
+	}
+
+	cases := []struct {
+		dep            *Dependency
+		origin, remote string
+	}{
+		{&Dependency{Name: "github.com/pkg/errors"}, "https://github.com/pkg/errors", "https://github.com/pkg/errors"},
+		{&Dependency{Name: "example.com/lib", Repository: "https://git.corp.com/lib.git"}, "https://git.corp.com/lib.git", "https://git.corp.com/lib.git"},
+		{&Dependency{Name: "example.com/fork"}, "https://git.corp.com/fork.git", "https://git.corp.com/fork.git"},
+		{&Dependency{Name: "example.com/local"}, "https://example.com/local", "https://example.com/local"},
+		{&Dependency{Name: "golang.org/x/sys"}, "https://golang.org/x/sys", "https://github.com/golang/sys"},
+	}
+
+	for _, tc := range cases {
+		if origin, remote := ctx.OriginOf(tc.dep), ctx.RemoteOf(tc.dep); origin != tc.origin || remote != tc.remote {
+			t.Errorf("%s: origin %s remote %s, expected %s %s", tc.dep.Name, origin, remote, tc.origin, tc.remote)
+		}
+	}
+}
+
+func TestLocalReplace(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(root)
+
+	local := filepath.Join(root, "local")
+	os.MkdirAll(filepath.Join(local, ".git"), 0755)
+	if err := ioutil.WriteFile(filepath.Join(local, "lib.go"), []byte("package lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := &Ctx{Logger: NewLogger(), Config: &Config{}, LockFile: NewLockFile(), Settings: DefaultSettings()}
+	ctx.Replaces = []*Replace{{Name: "example.com/lib", Path: "local"}}
+	lock, err := ctx.GetDependency(&Dependency{Name: "example.com/lib"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !lock.IsReplaced() || lock.Replace != "local" || lock.Reversion != "" {
+		t.Errorf("lock: %+v", lock)
+	}
+
+	if readString(t, filepath.Join("vendor", "example.com", "lib", "lib.go")) != "package lib\n" {
+		t.Error("local replace is not in vendor")
+	}
+}
+
+func TestCopyDir(t *testing.T) {
+	src := tempDir(t)
+	defer os.RemoveAll(src)
+
+	files := map[string]string{"a.go": "package a\n", "sub/b.go": "package sub\n", ".git/HEAD": "ref\n"}
+	for name, body := range files {
+		file := filepath.Join(src, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(file), 0755)
+		if err := ioutil.WriteFile(file, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dst := filepath.Join(src, "..", filepath.Base(src)+"-copy")
+	defer os.RemoveAll(dst)
+	if err := CopyDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, body := range files {
+		file := filepath.Join(dst, filepath.FromSlash(name))
+		if name == ".git/HEAD" {
+			if Exists(file) {
+				t.Errorf("%s is copied", name)
+			}
+			continue
+		}
+
+		if readString(t, file) != body {
+			t.Errorf("%s is not copied", name)
+		}
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(root)
+
+	l := NewLockFile()
+	l.Set(&Lock{Name: "example.com/a", Reversion: "1"})
+	l.Set(&Lock{Name: "example.com/b", Replace: "../b"})
+	l.Set(&Lock{Name: "example.com/a", Reversion: "2"})
+	if len(l.Imports) != 2 || l.Find("example.com/a").Reversion != "2" || !l.Find("example.com/b").IsReplaced() {
+		t.Fatalf("locks: %+v", l.Imports)
+	}
+
+	if err := l.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewLockFile()
+	if err := loaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if b := loaded.Find("example.com/b"); b == nil || b.Replace != "../b" {
+		t.Errorf("loaded: %+v", loaded.Imports)
+	}
+
+	if !loaded.Del("example.com/a") || loaded.Del("example.com/a") || loaded.Find("example.com/a") != nil {
+		t.Errorf("lock is not deleted: %+v", loaded.Imports)
+	}
+}
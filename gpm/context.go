@@ -2,6 +2,7 @@ package gpm
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -20,7 +21,14 @@ type Ctx struct {
 	*cli.Context
 	*Logger
 	*Config
-	CacheDir string
+	LockFile   *LockFile
+	Settings   *Settings // effective settings of all layers
+	loaded     *Settings // settings of system, user, env and flags, without project
+	CacheDir   string
+	StartDir   string // dir where gpm was run, or -C dir
+	WorkDir    string // project root which contains gpm.yaml
+	Offline    bool   // use cache only, never fetch from network
+	FetchBases bool   // fetch missing bases of extends while loading, set by install and update
 }
 
 // NewCtx create context
@@ -33,45 +41,415 @@ func NewCtx() *Ctx {
 func (ctx *Ctx) init() {
 	ctx.Logger = NewLogger()
 	ctx.Config = NewConfig()
-	if home, err := Home(); err != nil {
+	ctx.LockFile = NewLockFile()
+	if _, err := Home(); err != nil {
 		ctx.Die("cannot get home")
-		ctx.CacheDir = filepath.Join(home, ".gpm")
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		ctx.Die("cannot get work dir:%+v", err)
+	}
+
+	ctx.StartDir = wd
+	ctx.WorkDir = wd
+}
+
+// ChangeDir run as if gpm was started in dir, used by -C
+func (ctx *Ctx) ChangeDir(dir string) error {
+	if err := ctx.SetWorkDir(dir); err != nil {
+		return err
+	}
+
+	ctx.StartDir = ctx.WorkDir
+	return nil
+}
+
+// SetWorkDir 设置工作目录,所有相对路径都相对于工作目录
+func (ctx *Ctx) SetWorkDir(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chdir(abs); err != nil {
+		return err
+	}
+
+	ctx.WorkDir = abs
+	return nil
+}
+
+// AutoChangeDir 自动向上查找配置所在目录,并切换到此目录
+func (ctx *Ctx) AutoChangeDir() bool {
+	root := FindRoot(ctx.StartDir)
+	if root == "" {
+		return false
+	}
+
+	if root != ctx.WorkDir {
+		if err := ctx.SetWorkDir(root); err != nil {
+			ctx.Die("change work dir fail:%+v", err)
+		}
+	}
+
+	return true
+}
+
+// ArgPath returns absolute path of path given in command line,
+// which is relative to the dir where gpm was run
+func (ctx *Ctx) ArgPath(path string) string {
+	path = ExpandHome(path)
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(ctx.StartDir, path)
+}
+
+// RelPath returns path given in command line relative to project root,
+// used for paths saved in gpm.yaml
+func (ctx *Ctx) RelPath(path string) string {
+	abs := ctx.ArgPath(path)
+	rel, err := filepath.Rel(ctx.WorkDir, abs)
+	if err != nil {
+		return abs
+	}
+
+	return filepath.ToSlash(rel)
+}
+
+// FindRoot search gpm.yaml from dir upward, returns empty if not found
+func FindRoot(dir string) string {
+	for {
+		if Exists(filepath.Join(dir, ConfName)) {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+
+		dir = parent
 	}
 }
 
 // MustLoad load config and die if not exists
+// gpm.yaml is linted before bases are resolved, bases are linted when loaded
 func (ctx *Ctx) MustLoad() {
-	err := ctx.Load()
-	if err != nil {
+	if !ctx.AutoChangeDir() {
 		ctx.Die("not find config,use gpm init to create")
 	}
+
+	issues, err := Lint(ConfName)
+	if err != nil {
+		ctx.Die("lint config fail:%+v", err)
+	}
+
+	failed := false
+	for _, issue := range issues {
+		if !issue.Warning {
+			ctx.Error("%s", issue)
+			failed = true
+		}
+	}
+
+	if failed {
+		ctx.Die("invalid %s, run gpm lint for details", ConfName)
+	}
+
+	if Exists(LockName) {
+		if err := ctx.LockFile.Load(); err != nil {
+			ctx.Die("load lock fail:%+v", err)
+		}
+	}
+
+	ctx.loadConfig()
+	ctx.applyProjectSettings()
+}
+
+// loadConfig 加载配置,extends引用的依赖未下载时,FetchBases为true则先下载再重新加载
+func (ctx *Ctx) loadConfig() {
+	fetched := make(map[string]bool)
+	for {
+		err := ctx.Load()
+		if err == nil {
+			return
+		}
+
+		missing, ok := err.(*MissingBaseError)
+		if !ok || !ctx.FetchBases || fetched[missing.Package] {
+			ctx.Die("load config fail:%+v", err)
+		}
+		fetched[missing.Package] = true
+
+		// imports are not merged yet, only local ones are here
+		dep := ctx.FindDependency(missing.Package)
+		if dep == nil {
+			ctx.Die("load config fail:%+v, %s should be in import", err, missing.Package)
+		}
+
+		// offline fetch uses cache only
+		ctx.Info("--> Fetch base %s", missing.File)
+		ctx.applyProjectSettings()
+		lock, err := ctx.GetDependency(dep, ctx.LockedVersion(dep))
+		if err != nil {
+			ctx.Die("get %s fail:%+v", dep.Name, err)
+		}
+
+		ctx.LockFile.Set(lock)
+		ctx.SaveLock()
+	}
+}
+
+// SaveLock 保存lock文件
+func (ctx *Ctx) SaveLock() {
+	if err := ctx.LockFile.Save(); err != nil {
+		ctx.Die("save lock fail:%+v", err)
+	}
 }
 
 // Get 类似go get,获取代码放入vendor中
 func (ctx *Ctx) Get(url string, version string) error {
 	if !strings.HasPrefix(url, PREFIX_GIT) && !strings.Contains(url, "://") {
-		url += "https://"
+		url = PREFIX_HTTPS + url
+	}
+
+	name := VendorPath(url)
+	dep := ctx.FindDependency(name)
+	if dep == nil {
+		dep = &Dependency{Name: name, Version: version}
+	}
+
+	// hosts are checked on the declared url, not the mirror
+	if err := ctx.CheckPolicy(dep, url); err != nil {
+		return err
+	}
+
+	url = ctx.Settings.Mirror(url)
+
+	_, err := ctx.fetch(name, url, version, func(repo vcs.Repo) error {
+		return ctx.CheckResolved(dep, repoVersion(repo))
+	})
+	return err
+}
+
+// GetDependency 获取依赖放入vendor中,会处理replace和patches,返回lock信息
+func (ctx *Ctx) GetDependency(dep *Dependency, version string) (*Lock, error) {
+	lock := &Lock{Name: dep.Name, Base: dep.Base}
+
+	url := ctx.RemoteOf(dep)
+	origin := ctx.OriginOf(dep)
+	if dep.IsArchive() && ctx.FindReplace(dep.Name) == nil {
+		url = dep.Archive
+		origin = dep.Archive
+	}
+
+	// local replace has no url and no version, only banned and exact minimum are checked
+	if r := ctx.FindReplace(dep.Name); r != nil && r.IsLocal() {
+		url = ""
+		origin = ""
+	}
+
+	// hosts are checked on the declared url, not the mirror
+	if err := ctx.CheckPolicy(dep, origin); err != nil {
+		return nil, err
+	}
+
+	if r := ctx.FindReplace(dep.Name); r != nil {
+		lock.Replace = r.Target()
+		ctx.Info("--> Replace %s => %s", dep.Name, lock.Replace)
+		if r.IsLocal() {
+			if dep.VerifySignature {
+				ctx.Warn("signature is not verified for local replace:%+v", dep.Name)
+			}
+
+			if len(dep.Patches) > 0 {
+				ctx.Warn("patches are ignored for local replace:%+v", dep.Name)
+			}
+
+			return lock, ctx.linkLocal(dep.Name, r.Path)
+		}
+	}
+
+	dir := filepath.Join("vendor", dep.Name)
+	if dep.IsArchive() && lock.Replace == "" {
+		if dep.VerifySignature {
+			return nil, fmt.Errorf("verify signature is not supported for archive:%+v", dep.Name)
+		}
+
+		if _, err := semver.NewVersion(dep.Version); err == nil {
+			lock.Version = dep.Version
+		}
+
+		// checked before anything is downloaded or unpacked
+		if err := ctx.CheckResolved(dep, lock.Version); err != nil {
+			return nil, err
+		}
+
+		if err := ctx.fetchArchive(dep); err != nil {
+			return nil, err
+		}
+
+		lock.Archive = dep.Archive
+		lock.Reversion = strings.ToLower(dep.Sha256)
+	} else {
+		// checked on the resolved checkout in cache, before export to vendor
+		repo, err := ctx.fetch(dep.Name, url, version, func(repo vcs.Repo) error {
+			if err := ctx.CheckResolved(dep, repoVersion(repo)); err != nil {
+				return err
+			}
+
+			if err := ctx.verifySignature(dep, repo, lock); err != nil {
+				return err
+			}
+
+			// patches are always applied to a pristine export
+			if len(dep.Patches) > 0 {
+				return os.RemoveAll(dir)
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		lock.Reversion, _ = repo.Version()
+		lock.Version = repoVersion(repo)
+	}
+
+	if len(dep.Patches) > 0 {
+		ctx.Info("--> Patch %s", dep.Name)
+		if err := ApplyPatches(dir, dep.Patches); err != nil {
+			return nil, err
+		}
+	}
+
+	hash, err := HashDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	lock.Hash = hash
+
+	return lock, nil
+}
+
+// repoVersion returns semver tag of current reversion, empty if none
+func repoVersion(repo vcs.Repo) string {
+	rev, err := repo.Version()
+	if err != nil {
+		return ""
+	}
+
+	tags, err := repo.TagsFromCommit(rev)
+	if err != nil {
+		return ""
+	}
+
+	return SemverTag(tags)
+}
+
+// RemoteOf returns the remote of dependency, fork url if replaced
+func (ctx *Ctx) RemoteOf(dep *Dependency) string {
+	return ctx.Settings.Mirror(ctx.OriginOf(dep))
+}
+
+// OriginOf returns the declared url of dependency or its fork, before mirrors are applied
+func (ctx *Ctx) OriginOf(dep *Dependency) string {
+	if r := ctx.FindReplace(dep.Name); r != nil && !r.IsLocal() {
+		return r.Repository
 	}
 
-	local, err := CacheLocal(url)
+	return dep.Remote()
+}
+
+// ExportPristine export dependency at reversion to dir, without patches
+func (ctx *Ctx) ExportPristine(dep *Dependency, reversion string, dir string) error {
+	if dep.IsArchive() && ctx.FindReplace(dep.Name) == nil {
+		file, err := ctx.cacheArchive(dep)
+		if err != nil {
+			return err
+		}
+
+		return Unpack(file, dir, dep.StripPrefix)
+	}
+
+	url := ctx.RemoteOf(dep)
+	local, err := ctx.CacheLocal(url)
 	if err != nil {
 		return err
 	}
 
-	// step1: get repo
-	repo, err := vcs.NewRepo(url, local)
+	repo, err := ctx.newRepo(url, local)
 	if err != nil {
 		return err
 	}
 
 	if !Exists(repo.LocalPath()) {
+		if ctx.Offline {
+			return fmt.Errorf("%s not in cache, cannot fetch in offline mode", url)
+		}
+
+		if err := repo.Get(); err != nil {
+			return err
+		}
+	}
+
+	if err := repo.UpdateVersion(reversion); err != nil {
+		return err
+	}
+
+	return repo.ExportDir(dir)
+}
+
+// LockedVersion returns the version which should be installed,
+// the locked reversion is used only if it came from the same source
+func (ctx *Ctx) LockedVersion(dep *Dependency) string {
+	lock := ctx.LockFile.Find(dep.Name)
+	if lock == nil || lock.Reversion == "" {
+		return dep.Version
+	}
+
+	replace := ""
+	if r := ctx.FindReplace(dep.Name); r != nil {
+		replace = r.Target()
+	}
+
+	if lock.Replace != replace {
+		return dep.Version
+	}
+
+	return lock.Reversion
+}
+
+// fetch 下载代码到cache中,并导出到vendor/name,check不为空时在导出前检查
+func (ctx *Ctx) fetch(name string, url string, version string, check func(repo vcs.Repo) error) (vcs.Repo, error) {
+	local, err := ctx.CacheLocal(url)
+	if err != nil {
+		return nil, err
+	}
+
+	// step1: get repo
+	repo, err := ctx.newRepo(url, local)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case ctx.Offline && !Exists(repo.LocalPath()):
+		err = fmt.Errorf("%s not in cache, cannot fetch in offline mode", url)
+	case ctx.Offline:
+	case !Exists(repo.LocalPath()):
 		err = repo.Get()
-	} else {
+	default:
 		err = repo.Update()
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// step2: update version
@@ -80,32 +458,131 @@ func (ctx *Ctx) Get(url string, version string) error {
 	newVersion, _ := repo.Current()
 
 	// step3: update repo
-	if err := repo.Update(); err != nil {
-		return err
+	if !ctx.Offline {
+		if err := repo.Update(); err != nil {
+			return nil, err
+		}
+	}
+
+	if check != nil {
+		if err := check(repo); err != nil {
+			return nil, err
+		}
 	}
 
 	// step4: export repo to vendor
-	// git@github.com:user/repo.git -> github.com/user/repo
-	// https://github.com/user/repo
+	dir := filepath.Join("vendor", name)
+	exportDir, _ := filepath.Abs(dir)
+	if IsSymlink(exportDir) {
+		// previously replaced by local directory
+		os.Remove(exportDir)
+	}
+
+	if !Exists(exportDir) || oldVersion != newVersion {
+		ctx.Info("--> Export %s, %s", name, exportDir)
+		if err := repo.ExportDir(exportDir); err != nil {
+			return nil, fmt.Errorf("repo export fail:%+v", err)
+		}
+	}
+
+	return repo, nil
+}
+
+// newRepo create repo, detect vcs from cache in offline mode
+func (ctx *Ctx) newRepo(url string, local string) (vcs.Repo, error) {
+	if !ctx.Offline || !Exists(local) {
+		return vcs.NewRepo(url, local)
+	}
+
+	// detect from remote may access network
+	vtype, err := vcs.DetectVcsFromFS(local)
+	if err != nil {
+		return nil, err
+	}
+
+	switch vtype {
+	case vcs.Git:
+		return vcs.NewGitRepo(url, local)
+	case vcs.Svn:
+		return vcs.NewSvnRepo(url, local)
+	case vcs.Hg:
+		return vcs.NewHgRepo(url, local)
+	case vcs.Bzr:
+		return vcs.NewBzrRepo(url, local)
+	}
+
+	return nil, vcs.ErrCannotDetectVCS
+}
+
+// CacheLocal return local path for repo in cache dir
+func (ctx *Ctx) CacheLocal(url string) (string, error) {
+	key, err := CacheKey(url)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(ctx.CacheDir, key), nil
+}
+
+// linkLocal 将本地目录链接到vendor/name,不支持链接时拷贝
+func (ctx *Ctx) linkLocal(name string, path string) error {
+	src, err := filepath.Abs(ExpandHome(path))
+	if err != nil {
+		return err
+	}
+
+	if fi, err := os.Stat(src); err != nil || !fi.IsDir() {
+		return fmt.Errorf("replace path is not a directory:%+v", path)
+	}
+
+	dst, _ := filepath.Abs(filepath.Join("vendor", name))
+	if err := os.RemoveAll(dst); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Symlink(src, dst); err == nil {
+		return nil
+	}
+
+	ctx.Debug("symlink fail, copy %s to %s", src, dst)
+	return CopyDir(src, dst)
+}
+
+// VendorPath returns the path in vendor for repo url
+// git@github.com:user/repo.git -> github.com/user/repo
+// https://github.com/user/repo -> github.com/user/repo
+func VendorPath(url string) string {
 	path := url
 	if strings.HasPrefix(url, PREFIX_GIT) {
 		path = path[len(PREFIX_GIT):]
-		path = strings.TrimRight(path, ".git")
+		path = strings.TrimSuffix(path, ".git")
 		path = strings.Replace(path, ":", "/", 1)
 	} else if strings.HasPrefix(url, PREFIX_HTTPS) {
 		path = path[len(PREFIX_HTTPS):]
 	}
 
-	dir := filepath.Join("vendor", path)
-	exportDir, _ := filepath.Abs(dir)
-	if !Exists(exportDir) || oldVersion != newVersion {
-		ctx.Info("--> Export %s, %s", path, exportDir)
-		if err := repo.ExportDir(exportDir); err != nil {
-			return fmt.Errorf("repo export fail:%+v", err)
+	return path
+}
+
+// SemverTag returns the highest semantic version in tags, empty if none
+func SemverTag(tags []string) string {
+	var found *semver.Version
+	for _, tag := range tags {
+		v, err := semver.NewVersion(tag)
+		if err == nil && (found == nil || v.GreaterThan(found)) {
+			found = v
 		}
 	}
 
-	return nil
+	if found == nil {
+		return ""
+	}
+
+	return found.Original()
 }
 
 // UpdateVersion update repo version
@@ -252,53 +729,6 @@ func (ctx *Ctx) UpdateVersion(repo vcs.Repo, ver string) error {
 // 	return ""
 // }
 
-// // SetWorkDir 设置工作目录
-// func (ctx *Ctx) SetWorkDir(wd string) {
-// 	os.Chdir(wd)
-// 	ctx.WorkDir = wd
-// 	ctx.gopath = ""
-// }
-
-// // AutoChangeDir 自动查找配置所在目录,并切换到此目录
-// func (ctx *Ctx) AutoChangeDir() bool {
-// 	if ctx.Conf.Exist() {
-// 		return true
-// 	}
-
-// 	gopath := ctx.GoPath()
-// 	if gopath == "" {
-// 		return false
-// 	}
-
-// 	root := filepath.Join(gopath, "src")
-// 	if !Exists(root) {
-// 		return false
-// 	}
-
-// 	wd := ""
-// 	filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
-// 		if err != nil {
-// 			return nil
-// 		}
-
-// 		if !fi.IsDir() && filepath.Base(path) == "gpm.yaml" {
-// 			wd = filepath.Dir(path)
-// 			return fmt.Errorf("find work dir")
-// 		}
-
-// 		return nil
-// 	})
-
-// 	if wd == "" {
-// 		ctx.Info("cannot find gpm.yaml")
-// 	}
-
-// 	// ctx.Debug("change dir:%+v", wd)
-// 	ctx.SetWorkDir(wd)
-
-// 	return true
-// }
-
 // // Load 加载配置文件
 // func (ctx *Ctx) Load() {
 // 	// 确保配置文件正常加载
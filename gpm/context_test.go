@@ -0,0 +1,79 @@
+package gpm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindRoot(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	// nested project is a root of its own
+	writeTree(t, root, map[string]string{
+		ConfName:                "package: app\n",
+		"cmd/app/main.go":       "package main\n",
+		"tools/gen/" + ConfName: "package: gen\n",
+	})
+	cases := []struct {
+		dir, expected string
+	}{
+		{".", "."},
+		{"cmd/app", "."},
+		{"tools", "."},
+		{"tools/gen", "tools/gen"},
+	}
+
+	for _, tc := range cases {
+		dir := filepath.Join(root, filepath.FromSlash(tc.dir))
+		if actual, expected := FindRoot(dir), filepath.Join(root, filepath.FromSlash(tc.expected)); actual != expected {
+			t.Errorf("FindRoot(%s) = %s, expected %s", tc.dir, actual, expected)
+		}
+	}
+}
+
+func TestAutoChangeDir(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	root, _ = filepath.EvalSymlinks(root)
+	writeTree(t, root, map[string]string{ConfName: "package: app\n", "cmd/app/main.go": "package main\n"})
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	start := filepath.Join(root, "cmd", "app")
+	ctx := &Ctx{Logger: NewLogger(), StartDir: start, WorkDir: start}
+	if !ctx.AutoChangeDir() || ctx.WorkDir != root {
+		t.Fatalf("work dir %s, expected %s", ctx.WorkDir, root)
+	}
+
+	if cwd, _ := os.Getwd(); cwd != root {
+		t.Errorf("cwd %s, expected %s", cwd, root)
+	}
+
+	// paths of command line are relative to start dir, paths saved in gpm.yaml to root
+	cases := []struct {
+		arg, abs, rel string
+	}{
+		{"main.go", filepath.Join(start, "main.go"), "cmd/app/main.go"},
+		{"../../patches/a.patch", filepath.Join(root, "patches", "a.patch"), "patches/a.patch"},
+		{root, root, "."},
+		{"", "", ""},
+	}
+
+	for _, tc := range cases {
+		if abs := ctx.ArgPath(tc.arg); abs != tc.abs {
+			t.Errorf("ArgPath(%s) = %s, expected %s", tc.arg, abs, tc.abs)
+		}
+
+		if tc.arg == "" {
+			continue
+		}
+
+		if rel := ctx.RelPath(tc.arg); rel != tc.rel {
+			t.Errorf("RelPath(%s) = %s, expected %s", tc.arg, rel, tc.rel)
+		}
+	}
+}
@@ -0,0 +1,532 @@
+package gpm
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml2 "gopkg.in/yaml.v2"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// EditYAML apply value to yaml source by editing only the changed entries,
+// comments, blank lines, key order and formatting of other entries are kept.
+// entries of a list are matched by package if they have one, otherwise by index
+func EditYAML(source []byte, value interface{}) ([]byte, error) {
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal(source, root); err != nil {
+		return nil, err
+	}
+
+	if len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode || root.Content[0].Style&yaml.FlowStyle != 0 {
+		return yaml2.Marshal(value)
+	}
+
+	text := string(source)
+	newline := strings.HasSuffix(text, "\n")
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	e := &yamlEditor{lines: lines, changed: make(map[*yaml.Node]bool)}
+	e.syncMapping(root.Content[0], reflect.ValueOf(value))
+	if len(e.changed) > 0 {
+		// edit again, aliases of changed anchors are expanded
+		e = &yamlEditor{lines: lines, changed: e.changed}
+		e.syncMapping(root.Content[0], reflect.ValueOf(value))
+	}
+
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	result := strings.Join(e.apply(), "\n")
+	if newline {
+		result += "\n"
+	}
+
+	return []byte(result), nil
+}
+
+// yamlEdit replace lines [start, end) with lines, insert if start == end
+type yamlEdit struct {
+	start int
+	end   int
+	lines []string
+	order int
+}
+
+type yamlEditor struct {
+	lines   []string
+	edits   []*yamlEdit
+	err     error
+	changed map[*yaml.Node]bool // anchored nodes edited or removed
+}
+
+// markAnchors mark anchored nodes of tree as changed if value is not v, all if v is invalid
+func (e *yamlEditor) markAnchors(node *yaml.Node, v reflect.Value) {
+	if node.Anchor != "" && (!v.IsValid() || !e.equal(node, v)) {
+		e.changed[node] = true
+	}
+
+	if !v.IsValid() {
+		for _, child := range node.Content {
+			e.markAnchors(child, v)
+		}
+	}
+}
+
+func (e *yamlEditor) replace(start int, end int, lines []string) {
+	e.edits = append(e.edits, &yamlEdit{start: start, end: end, lines: lines, order: len(e.edits)})
+}
+
+// apply edits from top to bottom, edits at the same line keep their order,
+// inserts in lines already replaced are placed after the replacement
+func (e *yamlEditor) apply() []string {
+	sort.SliceStable(e.edits, func(i, j int) bool {
+		a, b := e.edits[i], e.edits[j]
+		return a.start < b.start || (a.start == b.start && a.order < b.order)
+	})
+
+	lines := []string{}
+	next := 0
+	for _, ed := range e.edits {
+		if ed.start > next {
+			lines = append(lines, e.lines[next:ed.start]...)
+			next = ed.start
+		}
+		lines = append(lines, ed.lines...)
+		if ed.end > next {
+			next = ed.end
+		}
+	}
+
+	return append(lines, e.lines[next:]...)
+}
+
+// render marshal value with yaml.v2 like Save, indented by indent spaces
+func (e *yamlEditor) render(value interface{}, indent int) []string {
+	data, err := yaml2.Marshal(value)
+	if err != nil {
+		e.err = err
+		return nil
+	}
+
+	prefix := strings.Repeat(" ", indent)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for i := range lines {
+		lines[i] = prefix + lines[i]
+	}
+
+	return lines
+}
+
+// blockEnd returns the line after the last content line of block starting at start,
+// lines deeper than indent belong to block, trailing comments not deeper belong to the next block
+func (e *yamlEditor) blockEnd(start int, indent int, dash bool) int {
+	end := start + 1
+	for i := start + 1; i < len(e.lines); i++ {
+		line := e.lines[i]
+		trimmed := strings.TrimLeft(line, " ")
+		n := len(line) - len(trimmed)
+		if trimmed == "" || (strings.HasPrefix(trimmed, "#") && n <= indent) {
+			continue
+		}
+
+		if n > indent || (dash && n == indent && (trimmed == "-" || strings.HasPrefix(trimmed, "- "))) {
+			end = i + 1
+			continue
+		}
+
+		break
+	}
+
+	return end
+}
+
+// blockStart returns the first line of comments right above start
+func (e *yamlEditor) blockStart(start int, indent int) int {
+	for start > 0 {
+		line := e.lines[start-1]
+		trimmed := strings.TrimLeft(line, " ")
+		if !strings.HasPrefix(trimmed, "#") || len(line)-len(trimmed) < indent {
+			break
+		}
+		start--
+	}
+
+	return start
+}
+
+// keyRange returns line range of key and its value
+func (e *yamlEditor) keyRange(key *yaml.Node, value *yaml.Node) (int, int) {
+	start := key.Line - 1
+	dash := value.Kind == yaml.SequenceNode && value.Style&yaml.FlowStyle == 0
+	return start, e.blockEnd(start, key.Column-1, dash)
+}
+
+// itemRange returns line range of sequence item, indent is column of dash
+func (e *yamlEditor) itemRange(item *yaml.Node) (int, int, int) {
+	start := item.Line - 1
+	indent := strings.LastIndex(string([]rune(e.lines[start])[:item.Column-1]), "-")
+	if indent < 0 {
+		indent = item.Column - 1
+	}
+
+	return start, e.blockEnd(start, indent, false), indent
+}
+
+// replaceKey render key and value again, used when value cannot be edited in place
+func (e *yamlEditor) replaceKey(name string, key *yaml.Node, value *yaml.Node, v reflect.Value) {
+	start, end := e.keyRange(key, value)
+	e.replace(start, end, e.render(yaml2.MapSlice{{Key: name, Value: v.Interface()}}, key.Column-1))
+}
+
+// syncMapping edit mapping node to struct or map value
+func (e *yamlEditor) syncMapping(node *yaml.Node, v reflect.Value) {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	keys := make(map[string]int)
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keys[node.Content[i].Value] = i
+	}
+
+	names := []string{}
+	values := make(map[string]reflect.Value)
+	omitempty := make(map[string]bool)
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			tags := strings.Split(f.Tag.Get("yaml"), ",")
+			if f.PkgPath != "" || tags[0] == "-" {
+				continue
+			}
+
+			name := tags[0]
+			if name == "" {
+				name = strings.ToLower(f.Name)
+			}
+
+			names = append(names, name)
+			values[name] = v.Field(i)
+			omitempty[name] = len(tags) > 1 && tags[1] == "omitempty"
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			names = append(names, k.String())
+			values[k.String()] = v.MapIndex(k)
+			omitempty[k.String()] = true
+		}
+		sort.Strings(names)
+
+		// keys removed from map
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if _, ok := values[node.Content[i].Value]; !ok {
+				e.removeKey(node.Content[i], node.Content[i+1])
+			}
+		}
+	}
+
+	// new keys are inserted after edits of nested values ending at the same line,
+	// zero values absent from source are not inserted
+	inserts := []string{}
+	for _, name := range names {
+		fv := values[name]
+		empty := isEmptyValue(fv)
+		i, ok := keys[name]
+		switch {
+		case ok && empty && omitempty[name]:
+			if !e.equal(node.Content[i+1], fv) {
+				e.removeKey(node.Content[i], node.Content[i+1])
+			}
+		case ok:
+			e.syncValue(name, node.Content[i], node.Content[i+1], fv)
+		case !empty:
+			inserts = append(inserts, name)
+		}
+	}
+
+	for _, name := range inserts {
+		e.insertKey(node, name, values[name])
+	}
+}
+
+// syncValue edit value of key
+func (e *yamlEditor) syncValue(name string, key *yaml.Node, node *yaml.Node, v reflect.Value) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			break
+		}
+		v = v.Elem()
+	}
+
+	e.markAnchors(node, v)
+	flow := node.Style&yaml.FlowStyle != 0
+	switch {
+	case node.Kind == yaml.AliasNode:
+		// keep alias unless changed
+		if e.changed[node.Alias] || !e.equal(node.Alias, v) {
+			e.replaceKey(name, key, node, v)
+		}
+	case v.Kind() == reflect.Struct || v.Kind() == reflect.Map:
+		if node.Kind != yaml.MappingNode || flow {
+			if !e.equal(node, v) {
+				e.replaceKey(name, key, node, v)
+			}
+			return
+		}
+		e.syncMapping(node, v)
+	case v.Kind() == reflect.Slice:
+		if node.Kind != yaml.SequenceNode || flow || len(node.Content) == 0 {
+			if !e.equal(node, v) {
+				e.replaceKey(name, key, node, v)
+			}
+			return
+		}
+		e.syncSequence(node, v)
+	default:
+		if !e.equal(node, v) && !e.setScalar(node, v) {
+			e.replaceKey(name, key, node, v)
+		}
+	}
+}
+
+// syncSequence edit items of sequence, items are matched by package or index
+func (e *yamlEditor) syncSequence(node *yaml.Node, v reflect.Value) {
+	elem := v.Type().Elem()
+	for elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	_, byPackage := yamlFields(elem)["package"]
+
+	matched := make(map[int]bool)
+	for i, item := range node.Content {
+		j := -1
+		if byPackage {
+			id := packageKey(nodePackage(item))
+			for k := 0; k < v.Len(); k++ {
+				if !matched[k] && packageKey(valuePackage(v.Index(k))) == id {
+					j = k
+					break
+				}
+			}
+		} else if i < v.Len() {
+			j = i
+		}
+
+		if j == -1 {
+			e.removeItem(item)
+			continue
+		}
+
+		matched[j] = true
+		e.syncItem(item, v.Index(j))
+	}
+
+	last := node.Content[len(node.Content)-1]
+	_, end, indent := e.itemRange(last)
+	for k := 0; k < v.Len(); k++ {
+		if !matched[k] {
+			e.replace(end, end, e.render([]interface{}{v.Index(k).Interface()}, indent))
+		}
+	}
+}
+
+// syncItem edit item of sequence
+func (e *yamlEditor) syncItem(node *yaml.Node, v reflect.Value) {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	e.markAnchors(node, v)
+	if node.Kind == yaml.MappingNode && node.Style&yaml.FlowStyle == 0 && v.Kind() == reflect.Struct {
+		e.syncMapping(node, v)
+		return
+	}
+
+	if node.Kind == yaml.AliasNode && !e.changed[node.Alias] && e.equal(node.Alias, v) {
+		return
+	}
+
+	if node.Kind != yaml.AliasNode && (e.equal(node, v) || (node.Kind == yaml.ScalarNode && e.setScalar(node, v))) {
+		return
+	}
+
+	start, end, indent := e.itemRange(node)
+	e.replace(start, end, e.render([]interface{}{v.Interface()}, indent))
+}
+
+func (e *yamlEditor) removeKey(key *yaml.Node, value *yaml.Node) {
+	e.markAnchors(value, reflect.Value{})
+	start, end := e.keyRange(key, value)
+	e.remove(e.blockStart(start, key.Column-1), end)
+}
+
+func (e *yamlEditor) removeItem(item *yaml.Node) {
+	e.markAnchors(item, reflect.Value{})
+	start, end, indent := e.itemRange(item)
+	e.remove(e.blockStart(start, indent), end)
+}
+
+// remove lines, and the blank line after if the block was separated by blank lines
+func (e *yamlEditor) remove(start int, end int) {
+	blank := func(i int) bool { return strings.TrimSpace(e.lines[i]) == "" }
+	if end < len(e.lines) && blank(end) && (start == 0 || blank(start-1) || strings.HasSuffix(strings.TrimSpace(e.lines[start-1]), ":")) {
+		end++
+	}
+
+	e.replace(start, end, nil)
+}
+
+// insertKey append key to the end of mapping
+func (e *yamlEditor) insertKey(node *yaml.Node, name string, v reflect.Value) {
+	lines := e.render(yaml2.MapSlice{{Key: name, Value: v.Interface()}}, node.Column-1)
+	key, value := node.Content[len(node.Content)-2], node.Content[len(node.Content)-1]
+	_, end := e.keyRange(key, value)
+	e.replace(end, end, lines)
+}
+
+// setScalar replace scalar text in line, returns false if scalar is multiline
+func (e *yamlEditor) setScalar(node *yaml.Node, v reflect.Value) bool {
+	if node.Kind != yaml.ScalarNode || node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return false
+	}
+
+	line := []rune(e.lines[node.Line-1])
+	start := node.Column - 1
+	end := start
+	switch {
+	case node.Style&yaml.DoubleQuotedStyle != 0:
+		for end = start + 1; end < len(line) && line[end] != '"'; end++ {
+			if line[end] == '\\' {
+				end++
+			}
+		}
+		end++
+	case node.Style&yaml.SingleQuotedStyle != 0:
+		for end = start + 1; end < len(line); end++ {
+			if line[end] == '\'' {
+				if end+1 < len(line) && line[end+1] == '\'' {
+					end++
+					continue
+				}
+				break
+			}
+		}
+		end++
+	default:
+		end = len(line)
+		if i := strings.Index(string(line[start:]), " #"); i != -1 {
+			end = start + len([]rune(string(line[start:])[:i]))
+		}
+		for end > start && line[end-1] == ' ' {
+			end--
+		}
+	}
+
+	if end > len(line) {
+		return false
+	}
+
+	data, err := yaml2.Marshal(v.Interface())
+	if err != nil || strings.Count(strings.TrimSuffix(string(data), "\n"), "\n") > 0 {
+		return false
+	}
+
+	// keep quote style of string
+	value := strings.TrimSuffix(string(data), "\n")
+	if v.Kind() == reflect.String {
+		switch {
+		case node.Style&yaml.DoubleQuotedStyle != 0:
+			value = strconv.Quote(v.String())
+		case node.Style&yaml.SingleQuotedStyle != 0:
+			value = "'" + strings.Replace(v.String(), "'", "''", -1) + "'"
+		}
+	}
+
+	text := string(line[:start]) + value + string(line[end:])
+	e.replace(node.Line-1, node.Line, []string{text})
+	return true
+}
+
+// equal returns true if node decodes to the same value
+func (e *yamlEditor) equal(node *yaml.Node, v reflect.Value) bool {
+	if !v.IsValid() {
+		return node.Tag == "!!null"
+	}
+
+	if isEmptyValue(v) && (node.Tag == "!!null" || (node.Kind != yaml.ScalarNode && len(node.Content) == 0)) {
+		return true
+	}
+
+	old := reflect.New(v.Type())
+	if err := node.Decode(old.Interface()); err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(old.Elem().Interface(), v.Interface())
+}
+
+// nodePackage returns value of package key in mapping node
+func nodePackage(node *yaml.Node) string {
+	if node.Kind != yaml.MappingNode {
+		return ""
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "package" {
+			return node.Content[i+1].Value
+		}
+	}
+
+	return ""
+}
+
+// valuePackage returns field of struct with yaml key package
+func valuePackage(v reflect.Value) string {
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	for i := 0; i < v.NumField(); i++ {
+		if strings.Split(v.Type().Field(i).Tag.Get("yaml"), ",")[0] == "package" {
+			return v.Field(i).String()
+		}
+	}
+
+	return ""
+}
+
+// packageKey remove @version of package, Load moves it to version
+func packageKey(name string) string {
+	if i := strings.Index(name, "@"); i != -1 {
+		return name[:i]
+	}
+
+	return name
+}
+
+// isEmptyValue returns true if value is omitted by omitempty, like yaml.v2
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Ptr, reflect.Interface:
+		// pointer to false or 0 is set explicitly
+		return v.IsNil() || (v.Elem().Kind() == reflect.Struct && isEmptyValue(v.Elem()))
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" && !isEmptyValue(v.Field(i)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,170 @@
+package gpm
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	yaml2 "gopkg.in/yaml.v2"
+)
+
+var update = flag.Bool("update", false, "update golden files of testdata")
+
+// editOps change config loaded from testdata, golden file is <case>.<op>.golden
+var editOps = map[string]func(cfg *Config){
+	"add": func(cfg *Config) {
+		cfg.Imports = append(cfg.Imports, &Dependency{Name: "github.com/google/go-cmp", Version: "^0.3.0"})
+	},
+	"remove": func(cfg *Config) {
+		if len(cfg.Imports) > 0 {
+			cfg.Imports = cfg.Imports[1:]
+		}
+	},
+	"set": func(cfg *Config) {
+		cfg.Version = "1.1.0"
+		if n := len(cfg.Imports); n > 0 {
+			cfg.Imports[0].Version = "^1.5.0"
+			cfg.Imports[n-1].Version = "~2.0.0"
+		}
+		if n := len(cfg.Targets); n > 0 {
+			cfg.Targets[n-1].Ldflags = "-s"
+		}
+	},
+}
+
+func TestEditYAML(t *testing.T) {
+	cases, err := filepath.Glob(filepath.Join("testdata", "edit", "*.yaml"))
+	if err != nil || len(cases) == 0 {
+		t.Fatalf("no testdata:%+v", err)
+	}
+
+	for _, file := range cases {
+		source, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// unchanged config keeps the source as is
+		if data, err := EditYAML(source, loadEditCase(t, source)); err != nil || string(data) != string(source) {
+			t.Errorf("%s: unchanged config is edited:%+v\n%s", file, err, data)
+		}
+
+		for op, fn := range editOps {
+			cfg := loadEditCase(t, source)
+			fn(cfg)
+
+			data, err := EditYAML(source, cfg)
+			if err != nil {
+				t.Errorf("%s %s: %+v", file, op, err)
+				continue
+			}
+
+			golden := strings.TrimSuffix(file, ".yaml") + "." + op + ".golden"
+			if *update {
+				if err := ioutil.WriteFile(golden, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if expected := readString(t, golden); string(data) != expected {
+				t.Errorf("%s %s:\n%s\nexpected:\n%s", file, op, data, expected)
+			}
+
+			// edited source decodes to the changed config
+			if actual, expected := marshalEditCase(t, loadEditCase(t, data)), marshalEditCase(t, cfg); actual != expected {
+				t.Errorf("%s %s: decoded config differs\n%s", file, op, data)
+			}
+		}
+	}
+}
+
+func TestEditYAMLFallback(t *testing.T) {
+	cfg := &Config{Name: "example.com/app", Version: "1.0.0"}
+	for _, source := range []string{"", "{package: example.com/app}", "- a\n"} {
+		data, err := EditYAML([]byte(source), cfg)
+		if err != nil {
+			t.Fatalf("%q: %+v", source, err)
+		}
+
+		if expected, _ := yaml2.Marshal(cfg); string(data) != string(expected) {
+			t.Errorf("%q is not marshaled again:\n%s", source, data)
+		}
+	}
+}
+
+func loadEditCase(t *testing.T, source []byte) *Config {
+	cfg := &Config{}
+	if err := yaml2.Unmarshal(source, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	return cfg
+}
+
+func marshalEditCase(t *testing.T, cfg *Config) string {
+	data, err := yaml2.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+func TestSaveConfig(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(root)
+
+	source := "package: app\nimport:\n- package: github.com/a/b\n  version: ^1.0.0\n"
+	cases := map[string]struct {
+		edit     func(cfg *Config)
+		expected string
+	}{
+		"none": {func(cfg *Config) {}, source},
+		"add": {
+			func(cfg *Config) { cfg.AddDependency(&Dependency{Name: "github.com/x/y"}) },
+			source + "- package: github.com/x/y\n",
+		},
+		"set": {
+			func(cfg *Config) {
+				cfg.Imports[0].Version = "~2.0.0"
+				cfg.Imports[0].Repository = "https://a.com/b.git"
+			},
+			"package: app\nimport:\n- package: github.com/a/b\n  version: ~2.0.0\n  repo: https://a.com/b.git\n",
+		},
+		"version": {
+			func(cfg *Config) { cfg.Version = "1.0.0"; cfg.AddDependency(&Dependency{Name: "github.com/x/y"}) },
+			source + "- package: github.com/x/y\nversion: 1.0.0\n",
+		},
+	}
+
+	for name, tc := range cases {
+		if err := ioutil.WriteFile(ConfName, []byte(source), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := NewConfig()
+		if err := cfg.Load(); err != nil {
+			t.Fatal(err)
+		}
+
+		tc.edit(cfg)
+		if err := cfg.Save(); err != nil {
+			t.Fatalf("%s: %+v", name, err)
+		}
+
+		if actual := readString(t, ConfName); actual != tc.expected {
+			t.Errorf("%s:\n%s\nexpected:\n%s", name, actual, tc.expected)
+		}
+
+		if err := NewConfig().Load(); err != nil {
+			t.Errorf("%s: saved config is invalid:%+v", name, err)
+		}
+	}
+}
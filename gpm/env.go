@@ -0,0 +1,95 @@
+package gpm
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// EnvAllowlist are environment variables inherited by go commands, others are dropped
+var EnvAllowlist = []string{
+	// system
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "LANG", "LC_ALL", "TMPDIR", "TMP", "TEMP",
+	"XDG_CACHE_HOME", "XDG_CONFIG_HOME", "SYSTEMROOT", "COMSPEC", "PATHEXT", "USERPROFILE", "APPDATA", "LOCALAPPDATA",
+	// toolchain
+	"GOROOT", "GOPATH", "GOCACHE", "GOTMPDIR", "GOENV", "GOPROXY", "GONOPROXY", "GOPRIVATE", "GOSUMDB", "GONOSUMDB", "GOINSECURE",
+	"CC", "CXX", "AR", "PKG_CONFIG", "PKG_CONFIG_PATH", "CGO_CFLAGS", "CGO_CPPFLAGS", "CGO_CXXFLAGS", "CGO_LDFLAGS",
+	// network
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy", "SSH_AUTH_SOCK",
+	"GIT_CONFIG_COUNT",
+}
+
+// BuildEnv returns environment of go commands, later ones override earlier:
+// allowlisted variables of gpm, module mode of vendor, extra, then env of gpm.yaml
+func (ctx *Ctx) BuildEnv(extra ...string) []string {
+	env := []string{}
+	for _, key := range EnvAllowlist {
+		if value, ok := os.LookupEnv(key); ok {
+			env = setEnv(env, key, value)
+		}
+	}
+
+	// credential helper of settings is passed by GIT_CONFIG_KEY_n
+	for _, kv := range os.Environ() {
+		if strings.HasPrefix(kv, "GIT_CONFIG_KEY_") || strings.HasPrefix(kv, "GIT_CONFIG_VALUE_") {
+			env = append(env, kv)
+		}
+	}
+
+	// gpm vendors into vendor/, go must not download or use module cache
+	switch {
+	case !Exists("go.mod"):
+		env = setEnv(env, "GO111MODULE", "off")
+	case Exists("vendor"):
+		env = setEnv(env, "GO111MODULE", "on")
+		env = setEnv(env, "GOFLAGS", "-mod=vendor")
+	default:
+		env = setEnv(env, "GO111MODULE", "on")
+	}
+
+	for _, kv := range extra {
+		kv := strings.SplitN(kv, "=", 2)
+		if len(kv) == 2 {
+			env = setEnv(env, kv[0], kv[1])
+		}
+	}
+
+	keys := []string{}
+	for key := range ctx.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// values of gpm.yaml can refer to the environment, eg: PATH: ${PATH}:/opt/bin
+	for _, key := range keys {
+		value := os.Expand(ctx.Env[key], func(name string) string {
+			return getEnv(env, name)
+		})
+		env = setEnv(env, key, value)
+	}
+
+	return env
+}
+
+// setEnv set key=value in env, replace the old one
+func setEnv(env []string, key string, value string) []string {
+	for i, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			env[i] = key + "=" + value
+			return env
+		}
+	}
+
+	return append(env, key+"="+value)
+}
+
+// getEnv returns value of key in env, empty if not set
+func getEnv(env []string, key string) string {
+	for _, kv := range env {
+		if strings.HasPrefix(kv, key+"=") {
+			return kv[len(key)+1:]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,71 @@
+package gpm
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestBuildEnv(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+
+	for key, value := range map[string]string{"GOPROXY": "https://proxy", "GPM_SECRET_TOKEN": "secret", "GIT_CONFIG_KEY_0": "credential.helper"} {
+		old, ok := os.LookupEnv(key)
+		os.Setenv(key, value)
+		if ok {
+			defer os.Setenv(key, old)
+		} else {
+			defer os.Unsetenv(key)
+		}
+	}
+
+	ctx := &Ctx{Config: &Config{Env: map[string]string{"PATH": "${PATH}:/opt/bin", "GOFLAGS": "-v", "CGO_ENABLED": "${CGO_ENABLED}1"}}}
+	cases := []struct {
+		files    map[string]string
+		expected map[string]string
+	}{
+		{nil, map[string]string{"GO111MODULE": "off", "GOFLAGS": "-v"}},
+		{map[string]string{"go.mod": "module app\n"}, map[string]string{"GO111MODULE": "on", "GOFLAGS": "-v"}},
+		{map[string]string{"vendor/modules.txt": ""}, map[string]string{"GO111MODULE": "on", "GOFLAGS": "-v"}},
+	}
+
+	for i, tc := range cases {
+		writeTree(t, dir, tc.files)
+		env := ctx.BuildEnv("CGO_ENABLED=0", "GOOS=linux", "invalid")
+		tc.expected["GOPROXY"] = "https://proxy"
+		tc.expected["GIT_CONFIG_KEY_0"] = "credential.helper"
+		tc.expected["GPM_SECRET_TOKEN"] = ""
+		tc.expected["PATH"] = os.Getenv("PATH") + ":/opt/bin"
+		tc.expected["GOOS"] = "linux"
+		tc.expected["CGO_ENABLED"] = "01"
+		for key, expected := range tc.expected {
+			if actual := getEnv(env, key); actual != expected {
+				t.Errorf("case %d: %s = %q, expected %q", i, key, actual, expected)
+			}
+		}
+	}
+
+	// -mod=vendor is set before env of gpm.yaml
+	ctx.Env = nil
+	if actual := getEnv(ctx.BuildEnv(), "GOFLAGS"); actual != "-mod=vendor" {
+		t.Errorf("GOFLAGS = %q, expected -mod=vendor", actual)
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	base := []string{"A=1", "B=2"}
+	env := MergeEnv(base, []string{"B=3", "C=x=y", "D"})
+	expected := []string{"A=1", "B=3", "C=x=y"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("MergeEnv = %v, expected %v", env, expected)
+	}
+
+	if base[1] != "B=2" {
+		t.Errorf("base is changed: %v", base)
+	}
+}
@@ -0,0 +1,380 @@
+package gpm
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	yaml2 "gopkg.in/yaml.v2"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ExtendsSep separate package and file of a base config in a dependency,
+// eg: github.com/corp/platform//gpm.base.yaml
+const ExtendsSep = "//"
+
+// MissingBaseError is returned when base config is in a dependency which is not fetched
+type MissingBaseError struct {
+	Package string
+	File    string
+}
+
+func (e *MissingBaseError) Error() string {
+	return fmt.Sprintf("base config %s not found, fetch %s first", e.File, e.Package)
+}
+
+// localSections are sections written in gpm.yaml, before inherited ones are merged
+type localSections struct {
+	Policy   *Policy
+	Licenses *LicensePolicy
+	Audit    *AuditConfig
+	Settings *Settings
+}
+
+// readConfig read config file without extends
+func readConfig(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	if err := yaml2.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("load %s fail:%+v", file, err)
+	}
+
+	cfg.source = data
+
+	// try fix name and version
+	for _, dep := range cfg.Imports {
+		if dep.Version == "" && strings.Contains(dep.Name, "@") {
+			tokens := strings.Split(dep.Name, "@")
+			dep.Name = tokens[0]
+			dep.Version = tokens[1]
+		}
+	}
+
+	return cfg, nil
+}
+
+// resolveExtends returns file of extends entry, local file is relative to dir,
+// file in dependency is relative to vendor/<package>
+func resolveExtends(dir string, ext string) (string, error) {
+	if isDependencyBase(ext) {
+		i := strings.Index(ext, ExtendsSep)
+		pkg, path := ext[:i], ext[i+len(ExtendsSep):]
+		file := filepath.Join("vendor", pkg, path)
+		if !Exists(file) {
+			return "", &MissingBaseError{Package: pkg, File: file}
+		}
+
+		return filepath.Abs(file)
+	}
+
+	file := ExpandHome(ext)
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+
+	if !Exists(file) {
+		return "", fmt.Errorf("base config not found:%+v", ext)
+	}
+
+	return filepath.Abs(file)
+}
+
+// isDependencyBase returns true if extends entry is <package>//<file>
+func isDependencyBase(ext string) bool {
+	i := strings.Index(ext, ExtendsSep)
+	return i > 0 && !strings.Contains(ext[:i], ":")
+}
+
+// loadBase load base config and its bases, relative paths in it are resolved to absolute
+func loadBase(file string, visiting map[string]bool) (*Config, error) {
+	if visiting[file] {
+		return nil, fmt.Errorf("extends cycle:%+v", file)
+	}
+	visiting[file] = true
+	defer delete(visiting, file)
+
+	issues, err := Lint(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lintError(issues); err != nil {
+		return nil, err
+	}
+
+	base, err := readConfig(file)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Dir(file)
+	abs := func(path string) string {
+		path = ExpandHome(path)
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(dir, path)
+	}
+
+	for _, dep := range base.Imports {
+		for i, patch := range dep.Patches {
+			dep.Patches[i] = abs(patch)
+		}
+	}
+
+	for _, r := range base.Replaces {
+		r.Path = abs(r.Path)
+	}
+
+	if base.Policy != nil {
+		base.Policy.File = abs(base.Policy.File)
+		if err := base.Policy.LoadFile(); err != nil {
+			return nil, err
+		}
+	}
+
+	// machine keys are rejected by lint
+	if s := base.ProjectSettings; s != nil {
+		s.Cache = abs(s.Cache)
+	}
+
+	if err := base.extend(dir, visiting); err != nil {
+		return nil, err
+	}
+
+	return base, nil
+}
+
+// extend merge bases of config in order, later base overrides earlier, local overrides all
+func (cfg *Config) extend(dir string, visiting map[string]bool) error {
+	cfg.local = &localSections{Policy: cfg.Policy, Licenses: cfg.Licenses, Audit: cfg.Audit, Settings: cfg.ProjectSettings}
+	if len(cfg.Extends) == 0 {
+		return nil
+	}
+
+	merged := &Config{}
+	for _, ext := range cfg.Extends {
+		file, err := resolveExtends(dir, ext)
+		if err != nil {
+			return err
+		}
+
+		base, err := loadBase(file, visiting)
+		if err != nil {
+			return err
+		}
+
+		// base in a dependency is not trusted to change settings or redirect sources
+		if isDependencyBase(ext) {
+			base.ProjectSettings = nil
+			base.Replaces = nil
+		}
+
+		// deps of base are from its own bases, or the base itself
+		for _, dep := range base.Imports {
+			if dep.Base == "" {
+				dep.Base = ext
+			}
+		}
+
+		merged.inherit(base)
+	}
+
+	cfg.inherited = make(map[string]*Dependency)
+	for _, dep := range merged.Imports {
+		if cfg.FindDependency(dep.Name) == nil {
+			copied := *dep
+			cfg.inherited[dep.Name] = &copied
+		}
+	}
+
+	cfg.inheritedReplaces = make(map[string]*Replace)
+	for _, r := range merged.Replaces {
+		if cfg.FindReplace(r.Name) == nil {
+			copied := *r
+			cfg.inheritedReplaces[r.Name] = &copied
+		}
+	}
+
+	// policy file of local is loaded already
+	merged.inherit(&Config{Imports: cfg.Imports, Replaces: cfg.Replaces, Policy: cfg.Policy, Licenses: cfg.Licenses, Audit: cfg.Audit, ProjectSettings: cfg.ProjectSettings})
+	cfg.Imports = merged.Imports
+	cfg.Replaces = merged.Replaces
+	cfg.Policy = merged.Policy
+	cfg.Licenses = merged.Licenses
+	cfg.Audit = merged.Audit
+	cfg.ProjectSettings = merged.ProjectSettings
+	return nil
+}
+
+// inherit merge other into cfg, entries of other override the same ones of cfg
+func (cfg *Config) inherit(other *Config) {
+	for _, dep := range other.Imports {
+		copied := *dep
+		if old := cfg.FindDependency(dep.Name); old != nil {
+			*old = copied
+		} else {
+			cfg.Imports = append(cfg.Imports, &copied)
+		}
+	}
+
+	for _, r := range other.Replaces {
+		copied := *r
+		cfg.SetReplace(&copied)
+	}
+
+	if other.Policy != nil {
+		if cfg.Policy == nil {
+			cfg.Policy = &Policy{}
+		}
+
+		for _, p := range other.Policy.all() {
+			// a host must be allowed by every policy
+			cfg.Policy.Hosts = intersectHosts(cfg.Policy.Hosts, p.Hosts)
+			cfg.Policy.Banned = appendUnique(cfg.Policy.Banned, p.Banned...)
+			for name, min := range p.Minimum {
+				if cfg.Policy.Minimum == nil {
+					cfg.Policy.Minimum = make(map[string]string)
+				}
+
+				// the highest minimum applies
+				if old, ok := cfg.Policy.Minimum[name]; !ok || belowMinimum(old, min) {
+					cfg.Policy.Minimum[name] = min
+				}
+			}
+		}
+	}
+
+	if other.Licenses != nil {
+		if cfg.Licenses == nil {
+			cfg.Licenses = &LicensePolicy{}
+		}
+
+		cfg.Licenses.Allow = appendUnique(cfg.Licenses.Allow, other.Licenses.Allow...)
+		cfg.Licenses.Deny = appendUnique(cfg.Licenses.Deny, other.Licenses.Deny...)
+		cfg.Licenses.Ignore = appendUnique(cfg.Licenses.Ignore, other.Licenses.Ignore...)
+	}
+
+	if other.Audit != nil {
+		if cfg.Audit == nil {
+			cfg.Audit = &AuditConfig{}
+		}
+
+		if other.Audit.DB != "" {
+			cfg.Audit.DB = other.Audit.DB
+		}
+
+		if other.Audit.Severity != "" {
+			cfg.Audit.Severity = other.Audit.Severity
+		}
+	}
+
+	if other.ProjectSettings != nil {
+		if cfg.ProjectSettings == nil {
+			cfg.ProjectSettings = &Settings{}
+		}
+
+		for key, value := range other.ProjectSettings.Values() {
+			if !IsMachineKey(key) {
+				cfg.ProjectSettings.Set(key, value)
+			}
+		}
+	}
+}
+
+// saveView returns config to save, inherited entries are excluded unless changed
+func (cfg *Config) saveView() *Config {
+	if cfg.local == nil {
+		return cfg
+	}
+
+	out := *cfg
+	out.Imports = []*Dependency{}
+	for _, dep := range cfg.Imports {
+		if old, ok := cfg.inherited[dep.Name]; !ok || !reflect.DeepEqual(old, dep) {
+			out.Imports = append(out.Imports, dep)
+		}
+	}
+
+	out.Replaces = nil
+	for _, r := range cfg.Replaces {
+		if old, ok := cfg.inheritedReplaces[r.Name]; !ok || !reflect.DeepEqual(old, r) {
+			out.Replaces = append(out.Replaces, r)
+		}
+	}
+
+	out.Policy = cfg.local.Policy
+	out.Licenses = cfg.local.Licenses
+	out.Audit = cfg.local.Audit
+	out.ProjectSettings = cfg.local.Settings
+	return &out
+}
+
+// LocalSettings returns settings written in gpm.yaml, without inherited ones
+func (cfg *Config) LocalSettings() *Settings {
+	if cfg.local == nil {
+		cfg.local = &localSections{Settings: cfg.ProjectSettings}
+	}
+
+	if cfg.local.Settings == nil {
+		cfg.local.Settings = &Settings{}
+		if len(cfg.Extends) == 0 {
+			cfg.ProjectSettings = cfg.local.Settings
+		}
+	}
+
+	return cfg.local.Settings
+}
+
+// ResolvedYAML returns merged config, inherited imports are commented with their base
+func (cfg *Config) ResolvedYAML() ([]byte, error) {
+	data, err := yaml2.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal(data, root); err != nil {
+		return nil, err
+	}
+
+	doc := root.Content[0]
+	for i := 0; i+1 < len(doc.Content); i += 2 {
+		if doc.Content[i].Value != "import" {
+			continue
+		}
+
+		for _, item := range doc.Content[i+1].Content {
+			dep := cfg.FindDependency(nodePackage(item))
+			if dep != nil && dep.Base != "" && len(item.Content) > 1 {
+				item.Content[1].LineComment = "from " + dep.Base
+			}
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), enc.Close()
+}
+
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		if !hasString(list, item) {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
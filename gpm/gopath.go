@@ -0,0 +1,125 @@
+package gpm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WorkspaceDir is the private GOPATH of project, project is linked to src/<package>
+const WorkspaceDir = ".gpm/gopath"
+
+// FindGoPath returns GOPATH which dir is in as <gopath>/src/<name>, empty if not.
+// name is the package of project, base of dir if empty
+func FindGoPath(dir string, name string) string {
+	if name == "" || name == "." {
+		name = filepath.Base(dir)
+	}
+
+	// 向上查找src,dir相对src的路径必须为package
+	for parent := filepath.Dir(dir); parent != filepath.Dir(parent); parent = filepath.Dir(parent) {
+		if filepath.Base(parent) != "src" {
+			continue
+		}
+
+		if rel, err := filepath.Rel(parent, dir); err == nil && filepath.ToSlash(rel) == name {
+			return filepath.Dir(parent)
+		}
+	}
+
+	return ""
+}
+
+// Workspace create the private GOPATH, returns GOPATH and dir of project in it
+func (ctx *Ctx) Workspace() (string, string, error) {
+	name := ctx.Name
+	if name == "" || name == "." {
+		name = filepath.Base(ctx.WorkDir)
+	}
+
+	// name is from gpm.yaml, the link must stay in src
+	slashed := strings.Replace(name, "\\", "/", -1)
+	if filepath.IsAbs(name) || strings.HasPrefix(slashed, "/") || hasString(strings.Split(slashed, "/"), "..") {
+		return "", "", fmt.Errorf("invalid package %s for workspace", name)
+	}
+
+	gopath := filepath.Join(ctx.WorkDir, WorkspaceDir)
+	src := filepath.Join(gopath, "src")
+	link := filepath.Join(src, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(src, link); err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("invalid package %s for workspace", name)
+	}
+
+	// parents of link must not be symlinks, RemoveAll would follow them
+	if err := checkUnpackPath(src, filepath.Dir(link)); err != nil {
+		return "", "", err
+	}
+
+	// relative link keeps working if project is moved
+	target, err := filepath.Rel(filepath.Dir(link), ctx.WorkDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	if old, err := os.Readlink(link); err == nil && old == target {
+		return gopath, link, nil
+	}
+
+	if err := os.RemoveAll(link); err != nil {
+		return "", "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+		return "", "", err
+	}
+
+	if err := os.Symlink(target, link); err != nil {
+		return "", "", fmt.Errorf("create workspace fail:%+v", err)
+	}
+
+	return gopath, link, nil
+}
+
+// GoEnv select go and returns dir and environment to run go commands of project.
+// module projects run in project root, others run in GOPATH, which is gopath,
+// the GOPATH project is in, or the private workspace
+func (ctx *Ctx) GoEnv(gopath string) (string, []string, error) {
+	// go of the required version
+	if err := ctx.SelectGo(); err != nil {
+		return "", nil, err
+	}
+
+	dir := ctx.WorkDir
+	if Exists(filepath.Join(ctx.WorkDir, "go.mod")) && gopath == "" {
+		return dir, ctx.BuildEnv(), nil
+	}
+
+	if gopath == "" {
+		gopath = FindGoPath(ctx.WorkDir, ctx.Name)
+	}
+
+	if gopath == "" {
+		var err error
+		gopath, dir, err = ctx.Workspace()
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	// go uses PWD as working dir, so the link is not resolved
+	return dir, ctx.BuildEnv("GOPATH="+gopath, "PWD="+dir), nil
+}
+
+// MergeEnv returns base with values of env, used to run other than go commands
+func MergeEnv(base []string, env []string) []string {
+	result := append([]string{}, base...)
+	for _, kv := range env {
+		tokens := strings.SplitN(kv, "=", 2)
+		if len(tokens) == 2 {
+			result = setEnv(result, tokens[0], tokens[1])
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,66 @@
+package gpm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWorkspace(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	ctx := &Ctx{Logger: NewLogger(), Config: &Config{Name: "example.com/user/app"}, WorkDir: root}
+	gopath, dir, err := ctx.Workspace()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dir != filepath.Join(gopath, "src", "example.com", "user", "app") || !Exists(filepath.Join(dir, WorkspaceDir)) {
+		t.Fatalf("workspace %s is not linked to project", dir)
+	}
+
+	for _, name := range []string{"../app", "example.com/../../../app", "/etc/app", `..\app`} {
+		ctx.Name = name
+		if _, _, err := ctx.Workspace(); err == nil || !strings.Contains(err.Error(), "invalid package") {
+			t.Errorf("%s: %+v", name, err)
+		}
+	}
+
+	// parent replaced by a symlink out of workspace
+	outside := tempDir(t)
+	defer os.RemoveAll(outside)
+
+	os.RemoveAll(filepath.Join(gopath, "src", "example.com"))
+	if err := os.Symlink(outside, filepath.Join(gopath, "src", "example.com")); err != nil {
+		t.Skip("symlink not supported")
+	}
+
+	ctx.Name = "example.com/user/app"
+	if _, _, err := ctx.Workspace(); err == nil || !strings.Contains(err.Error(), "symlink") {
+		t.Errorf("workspace is created through symlink:%+v", err)
+	}
+}
+
+func TestFindGoPath(t *testing.T) {
+	root := filepath.FromSlash("/home/user/go")
+	cases := []struct {
+		dir, name, expected string
+	}{
+		{"src/github.com/user/project", "github.com/user/project", root},
+		{"src/project", "", root},
+		{"src/project", ".", root},
+		{"src/github.com/user/project", "example.com/project", ""},
+		{"src/github.com/user/project/src", "github.com/user/project/src", root},
+		{"work/project", "project", ""},
+		{"src", "", ""},
+	}
+
+	for _, tc := range cases {
+		dir := filepath.Join(root, filepath.FromSlash(tc.dir))
+		if actual := FindGoPath(dir, tc.name); actual != tc.expected {
+			t.Errorf("FindGoPath(%s, %s) = %s, expected %s", dir, tc.name, actual, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package gpm
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LicenseUnknown is the id of license file which cannot be classified
+const LicenseUnknown = "unknown"
+
+// LicenseFile is a license file found in package
+type LicenseFile struct {
+	Path string // relative to package dir
+	ID   string // SPDX identifier or LicenseUnknown
+}
+
+// NoticeName is the combined notices file name
+const NoticeName = "THIRD_PARTY_NOTICES"
+
+// LicenseReport is the license state of a vendored package
+type LicenseReport struct {
+	Name      string
+	License   string // SPDX expression, empty if no license file
+	Files     []*LicenseFile
+	Notices   []string // NOTICE files, relative to package dir
+	Violation string   // reason if disallowed by policy
+}
+
+var (
+	licenseNames   = []string{"license", "licence", "copying", "unlicense"}
+	licenseNonWord = regexp.MustCompile(`[^a-z0-9./]+`)
+	licenseVer3    = regexp.MustCompile(`version 3\b`)
+	licenseVer21   = regexp.MustCompile(`version 2\.1\b`)
+	licenseVer2    = regexp.MustCompile(`version 2\b`)
+)
+
+// DetectLicenses find and classify license files in top level of dir
+func DetectLicenses(dir string) []*LicenseFile {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	result := []*LicenseFile{}
+	for _, fi := range infos {
+		if fi.IsDir() || !isLicenseFile(fi.Name()) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			continue
+		}
+
+		id := ClassifyLicense(string(data))
+		if id == "" {
+			id = LicenseUnknown
+		}
+
+		result = append(result, &LicenseFile{Path: fi.Name(), ID: id})
+	}
+
+	return result
+}
+
+// LicenseExpression join ids of license files, eg: MIT AND Apache-2.0, empty if no license file
+func LicenseExpression(files []*LicenseFile) string {
+	ids := []string{}
+	for _, f := range files {
+		if !hasString(ids, f.ID) {
+			ids = append(ids, f.ID)
+		}
+	}
+
+	sort.Strings(ids)
+	return strings.Join(ids, " AND ")
+}
+
+// DetectNotices find NOTICE files in top level of dir
+func DetectNotices(dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	result := []string{}
+	for _, fi := range infos {
+		if !fi.IsDir() && strings.HasPrefix(strings.ToLower(fi.Name()), "notice") {
+			result = append(result, fi.Name())
+		}
+	}
+
+	return result
+}
+
+// Check returns the reason if license expression is disallowed, empty if allowed
+func (p *LicensePolicy) Check(name string, license string) string {
+	if hasString(p.Ignore, name) {
+		return ""
+	}
+
+	if license == "" {
+		return "no license file"
+	}
+
+	for _, id := range strings.Split(license, " AND ") {
+		switch {
+		case id == LicenseUnknown:
+			return "unknown license"
+		case hasString(p.Deny, id):
+			return "denied license " + id
+		case len(p.Allow) > 0 && !hasString(p.Allow, id):
+			return "license not allowed " + id
+		}
+	}
+
+	return ""
+}
+
+// LicenseReports scan all vendored dependencies, check policy if configured
+func (ctx *Ctx) LicenseReports() []*LicenseReport {
+	result := []*LicenseReport{}
+	for _, dep := range ctx.Imports {
+		dir := filepath.Join("vendor", dep.Name)
+		report := &LicenseReport{Name: dep.Name, Files: DetectLicenses(dir), Notices: DetectNotices(dir)}
+		report.License = LicenseExpression(report.Files)
+		if ctx.Licenses != nil {
+			report.Violation = ctx.Licenses.Check(dep.Name, report.License)
+		}
+
+		result = append(result, report)
+	}
+
+	return result
+}
+
+// CheckLicenses returns error if any dependency violates license policy
+func (ctx *Ctx) CheckLicenses() error {
+	if ctx.Licenses == nil {
+		return nil
+	}
+
+	violations := []string{}
+	for _, report := range ctx.LicenseReports() {
+		if report.Violation != "" {
+			violations = append(violations, fmt.Sprintf("%s: %s", report.Name, report.Violation))
+		}
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("license policy violation:\n  %s", strings.Join(violations, "\n  "))
+	}
+
+	return nil
+}
+
+// WriteNotices combine license and NOTICE files of all dependencies into file
+func (ctx *Ctx) WriteNotices(file string) error {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Third party notices for %s\n", ctx.Name)
+	for _, report := range ctx.LicenseReports() {
+		files := []string{}
+		for _, f := range report.Files {
+			files = append(files, f.Path)
+		}
+		files = append(files, report.Notices...)
+
+		if len(files) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(buf, "\n%s\n%s (%s)\n%s\n", strings.Repeat("=", 80), report.Name, report.License, strings.Repeat("=", 80))
+		for _, f := range files {
+			data, err := ioutil.ReadFile(filepath.Join("vendor", report.Name, f))
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(buf, "\n--- %s ---\n\n%s\n", f, bytes.TrimSpace(data))
+		}
+	}
+
+	return ioutil.WriteFile(file, buf.Bytes(), 0666)
+}
+
+func isLicenseFile(name string) bool {
+	name = strings.ToLower(name)
+	if strings.HasSuffix(name, ".go") {
+		return false
+	}
+
+	for _, prefix := range licenseNames {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClassifyLicense returns SPDX identifier by matching license text, empty if unknown
+func ClassifyLicense(text string) string {
+	text = " " + strings.TrimSpace(licenseNonWord.ReplaceAllString(strings.ToLower(text), " ")) + " "
+	head := text
+	if len(head) > 1000 {
+		head = head[:1000]
+	}
+
+	// GPL family is identified by the first title, full texts mention each other
+	title, pos := "", len(head)
+	for _, t := range []string{"affero general public license", "lesser general public license", "library general public license", "gnu general public license"} {
+		if i := strings.Index(head, t); i != -1 && i < pos {
+			title, pos = t, i
+		}
+	}
+
+	if title != "" {
+		ver := head[pos:]
+		if len(ver) > len(title)+100 {
+			ver = ver[:len(title)+100]
+		}
+
+		switch {
+		case title == "affero general public license":
+			return "AGPL-3.0"
+		case title == "library general public license":
+			return "LGPL-2.0"
+		case title == "lesser general public license" && licenseVer3.MatchString(ver):
+			return "LGPL-3.0"
+		case title == "lesser general public license":
+			return "LGPL-2.1"
+		case licenseVer3.MatchString(ver):
+			return "GPL-3.0"
+		case licenseVer2.MatchString(ver) && !licenseVer21.MatchString(ver):
+			return "GPL-2.0"
+		}
+
+		return ""
+	}
+
+	switch {
+	case strings.Contains(text, "mozilla public license version 2.0"):
+		return "MPL-2.0"
+	case strings.Contains(text, "apache license") && strings.Contains(text, "version 2.0"):
+		return "Apache-2.0"
+	case strings.Contains(text, "eclipse public license") && strings.Contains(text, "v 2.0"):
+		return "EPL-2.0"
+	case strings.Contains(text, "this is free and unencumbered software released into the public domain"):
+		return "Unlicense"
+	case strings.Contains(text, "cc0 1.0 universal"):
+		return "CC0-1.0"
+	case strings.Contains(text, "permission is hereby granted free of charge to any person obtaining a copy"):
+		return "MIT"
+	case strings.Contains(text, "permission to use copy modify and/or distribute this software for any purpose"):
+		return "ISC"
+	case strings.Contains(text, "redistribution and use in source and binary forms"):
+		if strings.Contains(text, "neither the name") || strings.Contains(text, "names of its contributors may be used to endorse") {
+			return "BSD-3-Clause"
+		}
+		return "BSD-2-Clause"
+	}
+
+	return ""
+}
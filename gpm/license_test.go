@@ -0,0 +1,97 @@
+package gpm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClassifyLicense(t *testing.T) {
+	cases := map[string]string{
+		"MIT License\n\nPermission is hereby granted, free of charge, to any person obtaining a copy": "MIT",
+		"Apache License\nVersion 2.0, January 2004":                                                   "Apache-2.0",
+		"Redistribution and use in source and binary forms, with or without modification...":          "BSD-2-Clause",
+		"Redistribution and use in source and binary forms... Neither the name of Google Inc.":        "BSD-3-Clause",
+		"Permission to use, copy, modify, and/or distribute this software for any purpose":            "ISC",
+		"Mozilla Public License Version 2.0":                                                          "MPL-2.0",
+		"This is free and unencumbered software released into the public domain.":                     "Unlicense",
+		"GNU GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007":                                         "GPL-3.0",
+		"GNU GENERAL PUBLIC LICENSE\nVersion 2, June 1991":                                            "GPL-2.0",
+		"GNU LESSER GENERAL PUBLIC LICENSE\nVersion 3, 29 June 2007\n... GNU General Public License":  "LGPL-3.0",
+		"GNU LESSER GENERAL PUBLIC LICENSE\nVersion 2.1, February 1999":                               "LGPL-2.1",
+		"GNU AFFERO GENERAL PUBLIC LICENSE\nVersion 3, 19 November 2007":                              "AGPL-3.0",
+		"All rights reserved.": "",
+	}
+
+	for text, expected := range cases {
+		if actual := ClassifyLicense(text); actual != expected {
+			t.Errorf("ClassifyLicense(%q) = %q, expected %q", text, actual, expected)
+		}
+	}
+}
+
+func TestLicensePolicy(t *testing.T) {
+	p := &LicensePolicy{Allow: []string{"MIT", "Apache-2.0"}, Deny: []string{"GPL-3.0"}, Ignore: []string{"example.com/internal"}}
+	cases := []struct {
+		name, license, violation string
+	}{
+		{"a", "MIT", ""},
+		{"a", "Apache-2.0 AND MIT", ""},
+		{"a", "", "no license file"},
+		{"a", "MIT AND unknown", "unknown license"},
+		{"a", "GPL-3.0", "denied license GPL-3.0"},
+		{"a", "ISC", "license not allowed ISC"},
+		{"example.com/internal", "", ""},
+	}
+
+	for _, tc := range cases {
+		if actual := p.Check(tc.name, tc.license); actual != tc.violation {
+			t.Errorf("Check(%s, %s) = %q, expected %q", tc.name, tc.license, actual, tc.violation)
+		}
+	}
+}
+
+func TestLicenseReports(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(root)
+
+	writeTree(t, filepath.Join("vendor", "example.com", "a"), map[string]string{
+		"LICENSE":     "MIT License\n\nPermission is hereby granted, free of charge, to any person obtaining a copy",
+		"COPYING.gpl": "custom terms",
+		"NOTICE":      "Copyright a\n",
+		"licenses/x":  "not top level",
+		"license.go":  "package a\n",
+		"README.md":   "readme",
+	})
+	writeTree(t, filepath.Join("vendor", "example.com", "b"), map[string]string{"b.go": "package b\n"})
+
+	ctx := &Ctx{Config: &Config{Name: "example.com/app", Licenses: &LicensePolicy{Allow: []string{"MIT"}}}}
+	ctx.Imports = []*Dependency{{Name: "example.com/a"}, {Name: "example.com/b"}}
+
+	reports := ctx.LicenseReports()
+	if a := reports[0]; a.License != "MIT AND unknown" || len(a.Files) != 2 || len(a.Notices) != 1 || a.Violation != "unknown license" {
+		t.Errorf("report of a: %+v", a)
+	}
+
+	if b := reports[1]; b.License != "" || b.Violation != "no license file" {
+		t.Errorf("report of b: %+v", b)
+	}
+
+	if err := ctx.CheckLicenses(); err == nil || !strings.Contains(err.Error(), "example.com/b: no license file") {
+		t.Errorf("violation is not reported:%+v", err)
+	}
+
+	if err := ctx.WriteNotices(NoticeName); err != nil {
+		t.Fatal(err)
+	}
+
+	notices := readString(t, NoticeName)
+	if !strings.Contains(notices, "example.com/a (MIT AND unknown)") || !strings.Contains(notices, "Copyright a") || strings.Contains(notices, "example.com/b") {
+		t.Errorf("notices:\n%s", notices)
+	}
+}
@@ -0,0 +1,470 @@
+package gpm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	lintErrLine  = regexp.MustCompile(`line (\d+)`)
+	lintCommit   = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+	lintRef      = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+	lintPlatform = regexp.MustCompile(`^[a-z0-9]+/[a-z0-9]+$`)
+	lintIdent    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	lintSymbol   = regexp.MustCompile(`^[A-Za-z0-9._/-]+\.[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// LintIssue is a problem found in config file
+type LintIssue struct {
+	File    string
+	Line    int
+	Column  int
+	Warning bool // warnings are not reported by MustLoad
+	Message string
+}
+
+func (i *LintIssue) String() string {
+	level := "error"
+	if i.Warning {
+		level = "warning"
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s: %s", i.File, i.Line, i.Column, level, i.Message)
+}
+
+// Lint check config file strictly, unknown keys are reported
+func Lint(file string) ([]*LintIssue, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	return LintData(file, data), nil
+}
+
+// lintError returns errors of issues as one error, nil if only warnings
+func lintError(issues []*LintIssue) error {
+	errs := []string{}
+	for _, issue := range issues {
+		if !issue.Warning {
+			errs = append(errs, issue.String())
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("invalid config:\n%s", strings.Join(errs, "\n"))
+}
+
+// LintData check content of config file, issues are sorted by position
+func LintData(file string, data []byte) []*LintIssue {
+	l := &linter{file: file, nodes: make(map[string]*yaml.Node)}
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal(data, root); err != nil {
+		// syntax error has line only
+		line := 1
+		if m := lintErrLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+
+		l.issues = append(l.issues, &LintIssue{File: file, Line: line, Column: 1, Message: err.Error()})
+		return l.issues
+	}
+
+	if len(root.Content) == 0 {
+		return nil
+	}
+
+	doc := root.Content[0]
+	l.walk(doc, reflect.TypeOf(Config{}), "")
+
+	// type errors are reported by walk, values decoded partially are still checked
+	cfg := &Config{}
+	if err := doc.Decode(cfg); err != nil && len(l.issues) == 0 {
+		l.errorf(doc, "%+v", err)
+	}
+	l.check(cfg)
+
+	sort.SliceStable(l.issues, func(i, j int) bool {
+		a, b := l.issues[i], l.issues[j]
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+
+	return l.issues
+}
+
+type linter struct {
+	file   string
+	issues []*LintIssue
+	nodes  map[string]*yaml.Node // path -> value node, eg: import.0.version
+}
+
+func (l *linter) errorf(node *yaml.Node, format string, args ...interface{}) {
+	l.issues = append(l.issues, &LintIssue{File: l.file, Line: node.Line, Column: node.Column, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) warnf(node *yaml.Node, format string, args ...interface{}) {
+	l.errorf(node, format, args...)
+	l.issues[len(l.issues)-1].Warning = true
+}
+
+// node returns value node of path, or the nearest parent
+func (l *linter) node(path string) *yaml.Node {
+	for {
+		if n, ok := l.nodes[path]; ok {
+			return n
+		}
+
+		i := strings.LastIndex(path, ".")
+		if i == -1 {
+			return l.nodes[""]
+		}
+
+		path = path[:i]
+	}
+}
+
+// walk check node against go type, record position of every value
+func (l *linter) walk(node *yaml.Node, t reflect.Type, path string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if node.Kind == yaml.AliasNode && node.Alias != nil {
+		node = node.Alias
+	}
+
+	l.nodes[path] = node
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	name := path
+	if name == "" {
+		name = "document"
+	}
+
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			l.errorf(node, "%s should be a mapping", name)
+			return
+		}
+
+		fields := yamlFields(t)
+		seen := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if seen[key.Value] {
+				l.errorf(key, "duplicate key %s", key.Value)
+				continue
+			}
+			seen[key.Value] = true
+
+			if t.Kind() == reflect.Map {
+				l.walk(value, t.Elem(), joinPath(path, key.Value))
+				continue
+			}
+
+			ft, ok := fields[key.Value]
+			if !ok {
+				if s := suggestKey(key.Value, fields); s != "" {
+					l.errorf(key, "unknown key %s, did you mean %s?", key.Value, s)
+				} else {
+					l.errorf(key, "unknown key %s", key.Value)
+				}
+				continue
+			}
+
+			l.walk(value, ft, joinPath(path, key.Value))
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			l.errorf(node, "%s should be a list", name)
+			return
+		}
+
+		for i, item := range node.Content {
+			l.walk(item, t.Elem(), joinPath(path, strconv.Itoa(i)))
+		}
+	case reflect.Bool:
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			l.errorf(node, "%s should be true or false", name)
+		}
+	default:
+		if node.Kind != yaml.ScalarNode {
+			l.errorf(node, "%s should be a string", name)
+		}
+	}
+}
+
+// check values of decoded config
+func (l *linter) check(cfg *Config) {
+	names := make(map[string]bool)
+	for i, dep := range cfg.Imports {
+		path := fmt.Sprintf("import.%d", i)
+		if dep.Version == "" && strings.Contains(dep.Name, "@") {
+			tokens := strings.Split(dep.Name, "@")
+			dep.Name, dep.Version = tokens[0], tokens[1]
+		}
+
+		if dep.Name == "" {
+			l.errorf(l.node(path), "import without package")
+			continue
+		}
+
+		if names[dep.Name] {
+			l.errorf(l.node(path+".package"), "duplicate package %s", dep.Name)
+		}
+		names[dep.Name] = true
+
+		if dep.Version != "" {
+			if _, err := semver.NewConstraint(dep.Version); err != nil {
+				if lintCommit.MatchString(dep.Version) || lintRef.MatchString(dep.Version) {
+					l.warnf(l.node(path+".version"), "%s is not a semver constraint, used as branch or commit", dep.Version)
+				} else {
+					l.errorf(l.node(path+".version"), "invalid semver constraint %s", dep.Version)
+				}
+			}
+		}
+
+		if dep.Repository != "" && !validRepo(dep.Repository) {
+			l.errorf(l.node(path+".repo"), "malformed repo url %s", dep.Repository)
+		}
+
+		if dep.Archive != "" {
+			if u, err := url.Parse(dep.Archive); err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "file") {
+				l.errorf(l.node(path+".archive"), "malformed archive url %s", dep.Archive)
+			}
+
+			if dep.Sha256 == "" {
+				l.errorf(l.node(path), "archive of %s without sha256", dep.Name)
+			}
+		}
+	}
+
+	replaced := make(map[string]bool)
+	for i, r := range cfg.Replaces {
+		path := fmt.Sprintf("replace.%d", i)
+		switch {
+		case r.Name == "":
+			l.errorf(l.node(path), "replace without package")
+		case replaced[r.Name]:
+			l.errorf(l.node(path+".package"), "duplicate replace %s", r.Name)
+		case (r.Path == "") == (r.Repository == ""):
+			l.errorf(l.node(path), "replace %s needs one of path or repo", r.Name)
+		case r.Repository != "" && !validRepo(r.Repository):
+			l.errorf(l.node(path+".repo"), "malformed repo url %s", r.Repository)
+		}
+		replaced[r.Name] = true
+	}
+
+	if cfg.Go != "" {
+		if _, err := NewGoConstraint(cfg.Go); err != nil {
+			l.errorf(l.node("go"), "invalid go constraint %s", cfg.Go)
+		}
+	}
+
+	targets := make(map[string]bool)
+	for i, t := range cfg.Targets {
+		path := fmt.Sprintf("build.%d", i)
+		switch {
+		case t.Name == "":
+			l.errorf(l.node(path), "build target without name")
+		case targets[t.Name]:
+			l.errorf(l.node(path+".name"), "duplicate build target %s", t.Name)
+		case strings.ContainsAny(t.Name, `/\`) || strings.ContainsAny(t.Output, `/\`):
+			l.errorf(l.node(path), "name and output of build target %s should not contain path separator", t.Name)
+		}
+		targets[t.Name] = true
+
+		for j, platform := range t.Platforms {
+			if !lintPlatform.MatchString(platform) {
+				l.errorf(l.node(fmt.Sprintf("%s.platforms.%d", path, j)), "invalid platform %s, should be goos/goarch", platform)
+			}
+		}
+	}
+
+	if s := cfg.Stamp; s != nil {
+		symbols := map[string]string{"version": s.Version, "commit": s.Commit, "dirty": s.Dirty, "time": s.Time, "lock": s.Lock}
+		for key, symbol := range symbols {
+			if symbol != "" && !lintSymbol.MatchString(symbol) && !(s.File != "" && lintIdent.MatchString(symbol)) {
+				l.errorf(l.node("stamp."+key), "invalid variable %s, should be <package>.<name>", symbol)
+			}
+		}
+
+		if s.File != "" && !strings.HasSuffix(s.File, ".go") {
+			l.errorf(l.node("stamp.file"), "stamp file %s should be a .go file", s.File)
+		}
+	}
+
+	for _, name := range cfg.ScriptNames() {
+		path := "scripts." + name
+		if s := cfg.Scripts[name]; s == nil || (s.Run == "" && len(s.Deps) == 0) {
+			l.errorf(l.node(path), "script %s needs run or deps", name)
+			continue
+		}
+
+		if _, err := cfg.ScriptOrder(name); err != nil {
+			l.errorf(l.node(path+".deps"), "%+v", err)
+		}
+	}
+
+	if cfg.ProjectSettings != nil {
+		for _, key := range cfg.ProjectSettings.MachineKeys() {
+			l.errorf(l.node("settings."+key), "%s is only allowed in system or user settings", key)
+		}
+	}
+
+	for i, owner := range cfg.Owners {
+		if owner.Email == "" {
+			continue
+		}
+
+		if addr, err := mail.ParseAddress(owner.Email); err != nil || addr.Address != owner.Email {
+			l.errorf(l.node(fmt.Sprintf("owners.%d.email", i)), "invalid email %s", owner.Email)
+		}
+	}
+}
+
+// validRepo returns true for scp like url or url with host, file url needs path only
+func validRepo(repo string) bool {
+	if scpSyntaxRe.MatchString(repo) {
+		return true
+	}
+
+	u, err := url.Parse(repo)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "file":
+		return u.Path != ""
+	case "http", "https", "git", "ssh", "git+ssh", "svn", "svn+ssh", "bzr", "bzr+ssh":
+		return u.Host != "" && strings.Trim(u.Path, "/") != ""
+	}
+
+	return false
+}
+
+// yamlFields returns yaml key -> type of exported fields
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	result := make(map[string]reflect.Type)
+	if t.Kind() != reflect.Struct {
+		return result
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if f.PkgPath != "" || name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		result[name] = f.Type
+	}
+
+	return result
+}
+
+// suggestKey returns the known key closest to key, empty if none is close
+func suggestKey(key string, fields map[string]reflect.Type) string {
+	best, dist := "", 3
+	for name := range fields {
+		if d := editDistance(key, name); d < dist || (d == dist && name < best) {
+			best, dist = name, d
+		}
+	}
+
+	return best
+}
+
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+		}
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+
+	return path + "." + key
+}
+
+// JSONSchema returns json schema of gpm.yaml generated from Config
+func JSONSchema() map[string]interface{} {
+	schema := schemaOf(reflect.TypeOf(Config{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = ConfName
+	return schema
+}
+
+func schemaOf(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		props := make(map[string]interface{})
+		for name, ft := range yamlFields(t) {
+			props[name] = schemaOf(ft)
+		}
+
+		return map[string]interface{}{"type": "object", "properties": props, "additionalProperties": false}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int:
+		return map[string]interface{}{"type": "integer"}
+	}
+
+	return map[string]interface{}{"type": "string"}
+}
@@ -0,0 +1,92 @@
+package gpm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLintBase(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		ConfName:    "package: app\nextends:\n- base.yaml\nimport:\n",
+		"base.yaml": "import:\n- package: example.com/lib\n  version: ^1.0.0\n  verison: ^2.0.0\n",
+	}
+	for name, body := range files {
+		if err := ioutil.WriteFile(filepath.Join(root, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(root)
+
+	err := NewConfig().Load()
+	if err == nil || !strings.Contains(err.Error(), "base.yaml:4:3") || !strings.Contains(err.Error(), "verison") {
+		t.Errorf("invalid base is accepted:%+v", err)
+	}
+}
+
+func TestLintData(t *testing.T) {
+	cases := []struct {
+		source string
+		issue  string // file:line:column: level: message, empty if valid
+	}{
+		{"package: app\nversion: 1.0.0\nimport:\n- package: github.com/pkg/errors\n  version: ^0.8.0\n", ""},
+		{"package: app\nimprot:\n", "gpm.yaml:2:1: error: unknown key improt, did you mean import?"},
+		{"package: app\nimport:\n- package: a\n  version: ^1.0\n  reop: x\n", "gpm.yaml:5:3: error: unknown key reop, did you mean repo?"},
+		{"package: app\npackage: app2\n", "gpm.yaml:2:1: error: duplicate key package"},
+		{"package: app\nimport: github.com/a/b\n", "gpm.yaml:2:9: error: import should be a list"},
+		{"package: app\nimport:\n- package: a\n- package: a\n", "gpm.yaml:4:12: error: duplicate package a"},
+		{"package: app\nimport:\n- package: a\n  version: master\n", "gpm.yaml:4:12: warning: master is not a semver constraint, used as branch or commit"},
+		{"package: app\nimport:\n- package: a\n  version: ^^1\n", "gpm.yaml:4:12: error: invalid semver constraint ^^1"},
+		{"package: app\nimport:\n- package: a\n  archive: https://a.com/a.tar.gz\n", "gpm.yaml:3:3: error: archive of a without sha256"},
+		{"package: app\nimport:\n- package: a\n  repo: not a url\n", "gpm.yaml:4:9: error: malformed repo url not a url"},
+		{"package: app\nreplace:\n- package: a\n", "gpm.yaml:3:3: error: replace a needs one of path or repo"},
+		{"package: app\nowners:\n- email: not-an-email\n", "gpm.yaml:3:10: error: invalid email not-an-email"},
+		{"package: app\nbuild:\n- name: a\n  platforms: [linux]\n", "gpm.yaml:4:15: error: invalid platform linux, should be goos/goarch"},
+		{"package: app\ngo: newest\n", "gpm.yaml:2:5: error: invalid go constraint newest"},
+		{"package: app\nscripts:\n  a: {deps: [b]}\n  b: {deps: [a]}\n", "gpm.yaml:3:13: error: script cycle a -> b -> a"},
+		{"package: app\nsettings:\n  trusted-keys: ./keys\n", "gpm.yaml:3:17: error: trusted-keys is only allowed in system or user settings"},
+		{"package: app\nimport:\n- {package: a\n", "gpm.yaml:3:1: error: yaml: line 3: did not find expected ',' or '}'"},
+	}
+
+	for _, tc := range cases {
+		issues := LintData(ConfName, []byte(tc.source))
+		switch {
+		case tc.issue == "" && len(issues) != 0:
+			t.Errorf("%q: %v", tc.source, issues)
+		case tc.issue != "" && (len(issues) == 0 || issues[0].String() != tc.issue):
+			t.Errorf("%q: %v, expected %s", tc.source, issues, tc.issue)
+		}
+	}
+}
+
+func TestSuggestKey(t *testing.T) {
+	fields := yamlFields(reflect.TypeOf(Dependency{}))
+	cases := map[string]string{"verison": "version", "packge": "package", "sha265": "sha256", "unrelated": ""}
+	for key, expected := range cases {
+		if actual := suggestKey(key, fields); actual != expected {
+			t.Errorf("suggestKey(%s) = %q, expected %q", key, actual, expected)
+		}
+	}
+}
+
+// gpm.schema.json in repo is generated by gpm lint --schema
+func TestJSONSchema(t *testing.T) {
+	data, err := json.MarshalIndent(JSONSchema(), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := strings.TrimSpace(readString(t, filepath.Join("..", "gpm.schema.json"))); string(data) != expected {
+		t.Error("gpm.schema.json is out of date, run gpm lint --schema > gpm.schema.json")
+	}
+}
@@ -0,0 +1,94 @@
+package gpm
+
+import (
+	"io/ioutil"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Lock represents an individual locked dependency.
+type Lock struct {
+	Name      string `yaml:"name"`
+	Reversion string `yaml:"reversion,omitempty"`
+	Version   string `yaml:"version,omitempty"` // semver tag of reversion, if any
+	Replace   string `yaml:"replace,omitempty"` // local path or fork url, empty if not replaced
+	Archive   string `yaml:"archive,omitempty"` // archive url, reversion is sha256 of archive
+	Hash      string `yaml:"hash,omitempty"`    // sha256 of vendor dir, patches included
+	Signer    string `yaml:"signer,omitempty"`  // verified signer of tag
+	Base      string `yaml:"base,omitempty"`    // extends entry the dependency is inherited from
+}
+
+// IsReplaced returns true if the locked dependency comes from a replace
+func (l *Lock) IsReplaced() bool {
+	return l.Replace != ""
+}
+
+// LockFile represents a gpm.lock file.
+type LockFile struct {
+	Imports []*Lock `yaml:"imports"`
+}
+
+// NewLockFile create lock file
+func NewLockFile() *LockFile {
+	return &LockFile{}
+}
+
+// Load 加载lock文件
+func (l *LockFile) Load() error {
+	data, err := ioutil.ReadFile(LockName)
+	if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(data, l)
+}
+
+// Save 保存lock文件
+func (l *LockFile) Save() error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(LockName, data, 0666)
+}
+
+// LockHash returns sha256 of gpm.lock content
+func LockHash() (string, error) {
+	return HashFile(LockName)
+}
+
+// Find returns the locked dependency by name
+func (l *LockFile) Find(name string) *Lock {
+	for _, lock := range l.Imports {
+		if lock.Name == name {
+			return lock
+		}
+	}
+
+	return nil
+}
+
+// Set add or replace locked dependency
+func (l *LockFile) Set(lock *Lock) {
+	for i, old := range l.Imports {
+		if old.Name == lock.Name {
+			l.Imports[i] = lock
+			return
+		}
+	}
+
+	l.Imports = append(l.Imports, lock)
+}
+
+// Del remove locked dependency
+func (l *LockFile) Del(name string) bool {
+	for i, lock := range l.Imports {
+		if lock.Name == name {
+			l.Imports = append(l.Imports[:i], l.Imports[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
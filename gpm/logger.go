@@ -42,6 +42,7 @@ type Logger struct {
 	Debuging   bool
 	NoColor    bool
 	PanicOnDie bool
+	ExitCode   int // exit code of the last Die or Exit trapped by PanicOnDie
 }
 
 func (l *Logger) Info(msg string, args ...interface{}) {
@@ -68,7 +69,11 @@ func (l *Logger) Output(level int, msg string, args ...interface{}) {
 	l.Lock()
 	defer l.Unlock()
 	name := zLogName[level]
-	fmt.Printf("[%s]\t", name)
+	if l.NoColor || zLogColor[level] == "" {
+		fmt.Printf("[%s]\t", name)
+	} else {
+		fmt.Printf("\033[%sm[%s]\033[0m\t", zLogColor[level], name)
+	}
 	fmt.Printf(msg, args...)
 	fmt.Println("")
 }
@@ -96,13 +101,14 @@ func (l *Logger) Puts(msg string, args ...interface{}) {
 }
 
 func (l *Logger) Die(msg string, args ...interface{}) {
-	l.Exit(0, msg, args...)
+	l.Exit(1, msg, args...)
 }
 
 func (l *Logger) Exit(code int, msg string, args ...interface{}) {
 	l.Error(msg, args...)
 
 	if l.PanicOnDie {
+		l.ExitCode = code
 		panic("trapped")
 	}
 
@@ -0,0 +1,26 @@
+package gpm
+
+import "testing"
+
+func TestTrappedExitCode(t *testing.T) {
+	l := NewLogger()
+	l.PanicOnDie = true
+	for _, code := range []int{2, 1} {
+		func() {
+			defer func() {
+				if r := recover(); r != "trapped" {
+					t.Errorf("exit is not trapped:%+v", r)
+				}
+			}()
+
+			if code == 1 {
+				l.Die("fail")
+			}
+			l.Exit(code, "fail")
+		}()
+
+		if l.ExitCode != code {
+			t.Errorf("exit code %d, expected %d", l.ExitCode, code)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package gpm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ApplyPatches apply unified diff files to dir in order
+func ApplyPatches(dir string, patches []string) error {
+	for _, patch := range patches {
+		file, err := filepath.Abs(patch)
+		if err != nil {
+			return err
+		}
+
+		if !Exists(file) {
+			return fmt.Errorf("patch not found:%+v", patch)
+		}
+
+		out, err := gitNoRepo(dir, "apply", "-p1", "--whitespace=nowarn", file).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("apply patch %s to %s fail:\n%s", patch, dir, strings.TrimSpace(string(out)))
+		}
+	}
+
+	return nil
+}
+
+// DiffDir create unified diff from dir a to dir b, paths in diff are relative to both dirs
+func DiffDir(a string, b string) ([]byte, error) {
+	tmp, err := TempDir("diff")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	// copy to tmp/a and tmp/b, so --no-prefix produce a/file b/file
+	if err := CopyDir(a, filepath.Join(tmp, "a")); err != nil {
+		return nil, err
+	}
+
+	if err := CopyDir(b, filepath.Join(tmp, "b")); err != nil {
+		return nil, err
+	}
+
+	out, err := gitNoRepo(tmp, "diff", "--no-index", "--no-prefix", "--binary", "a", "b").Output()
+	if err == nil {
+		// exit code 0 means no difference
+		return nil, nil
+	}
+
+	// exit code 1 means has difference
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		return out, nil
+	}
+
+	return nil, fmt.Errorf("diff fail:%+v", err)
+}
+
+// gitNoRepo create git command in dir without discovering repository in parent dirs
+func gitNoRepo(dir string, args ...string) *exec.Cmd {
+	abs, _ := filepath.Abs(dir)
+	cmd := exec.Command("git", args...)
+	cmd.Dir = abs
+	cmd.Env = append(os.Environ(), "GIT_CEILING_DIRECTORIES="+filepath.Dir(abs))
+	return cmd
+}
@@ -0,0 +1,100 @@
+package gpm
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	for name, body := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(file), 0755)
+		if err := ioutil.WriteFile(file, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPatches(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	a, b, vendor := filepath.Join(root, "a"), filepath.Join(root, "b"), filepath.Join(root, "vendor")
+	writeTree(t, a, map[string]string{"lib.go": "package lib\n\nconst A = 1\n", "old.go": "package lib\n"})
+	writeTree(t, b, map[string]string{"lib.go": "package lib\n\nconst A = 2\n", "sub/new.go": "package sub\n"})
+	writeTree(t, vendor, map[string]string{"lib.go": "package lib\n\nconst A = 1\n", "old.go": "package lib\n"})
+
+	if diff, err := DiffDir(a, a); err != nil || diff != nil {
+		t.Fatalf("diff of same dir: %s %+v", diff, err)
+	}
+
+	diff, err := DiffDir(a, b)
+	if err != nil || !strings.Contains(string(diff), "+const A = 2") {
+		t.Fatalf("diff: %s %+v", diff, err)
+	}
+
+	patch := filepath.Join(root, "1.patch")
+	if err := ioutil.WriteFile(patch, diff, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ApplyPatches(vendor, []string{patch}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _ := HashDir(b)
+	if actual, _ := HashDir(vendor); actual != expected {
+		t.Error("patched dir differs")
+	}
+
+	// applied twice or missing
+	if err := ApplyPatches(vendor, []string{patch}); err == nil {
+		t.Error("patch is applied twice")
+	}
+
+	if err := ApplyPatches(vendor, []string{filepath.Join(root, "none.patch")}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("missing patch:%+v", err)
+	}
+}
+
+func TestHashDir(t *testing.T) {
+	hash := func(files map[string]string) string {
+		dir := tempDir(t)
+		defer os.RemoveAll(dir)
+
+		writeTree(t, dir, files)
+		sum, err := HashDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return sum
+	}
+
+	base := hash(map[string]string{"a.go": "package a\n", "b/c.go": "package b\n"})
+	cases := []struct {
+		name  string
+		files map[string]string
+		same  bool
+	}{
+		{"vcs dir", map[string]string{"a.go": "package a\n", "b/c.go": "package b\n", ".git/HEAD": "ref\n"}, true},
+		{"content", map[string]string{"a.go": "package a \n", "b/c.go": "package b\n"}, false},
+		{"renamed", map[string]string{"a.go": "package a\n", "b/d.go": "package b\n"}, false},
+		{"moved boundary", map[string]string{"a.g": "opackage a\n", "b/c.go": "package b\n"}, false},
+		{"empty file", map[string]string{"a.go": "package a\n", "b/c.go": "package b\n", "e": ""}, false},
+	}
+
+	for _, tc := range cases {
+		if actual := hash(tc.files); (actual == base) != tc.same {
+			t.Errorf("%s: same hash is %v", tc.name, !tc.same)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package gpm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// policy rules
+const (
+	RuleHosts   = "hosts"
+	RuleBanned  = "banned"
+	RuleMinimum = "minimum"
+)
+
+// Policy restrict where and what dependencies can be fetched
+type Policy struct {
+	File    string            `yaml:"file,omitempty"`    // external policy file, merged with this one
+	Hosts   []string          `yaml:"hosts,omitempty"`   // allowed hosts, support *.example.com, empty allows all
+	Banned  []string          `yaml:"banned,omitempty"`  // banned packages, sub packages included
+	Minimum map[string]string `yaml:"minimum,omitempty"` // package -> minimum version
+	loaded  *Policy           // loaded from File
+}
+
+// PolicyError is a violation of policy
+type PolicyError struct {
+	Rule    string
+	Package string
+	Reason  string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("policy violation [%s] %s: %s", e.Rule, e.Package, e.Reason)
+}
+
+// LoadFile load external policy file, relative path is relative to gpm.yaml
+func (p *Policy) LoadFile() error {
+	if p.File == "" {
+		return nil
+	}
+
+	file := ExpandHome(p.File)
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("load policy file fail:%+v", err)
+	}
+
+	loaded := &Policy{}
+	if err := yaml.Unmarshal(data, loaded); err != nil {
+		return fmt.Errorf("parse policy file %s fail:%+v", file, err)
+	}
+
+	if loaded.File != "" && !filepath.IsAbs(loaded.File) {
+		loaded.File = filepath.Join(filepath.Dir(file), loaded.File)
+	}
+
+	if err := loaded.LoadFile(); err != nil {
+		return err
+	}
+
+	p.loaded = loaded
+	return nil
+}
+
+// all returns this policy and the loaded ones
+func (p *Policy) all() []*Policy {
+	result := []*Policy{}
+	for ; p != nil; p = p.loaded {
+		result = append(result, p)
+	}
+
+	return result
+}
+
+// CheckPolicy evaluate policy before fetching dependency from url
+func (ctx *Ctx) CheckPolicy(dep *Dependency, url string) error {
+	if ctx.Policy == nil {
+		return nil
+	}
+
+	for _, p := range ctx.Policy.all() {
+		if host := urlHost(url); len(p.Hosts) > 0 && host != "" && !matchHost(p.Hosts, host) {
+			if err := ctx.exempt(dep, RuleHosts, "host not allowed: "+host); err != nil {
+				return err
+			}
+		}
+
+		for _, banned := range p.Banned {
+			if dep.Name == banned || strings.HasPrefix(dep.Name, banned+"/") {
+				if err := ctx.exempt(dep, RuleBanned, "package is banned"); err != nil {
+					return err
+				}
+			}
+		}
+
+		// exact version can be checked before fetching
+		if min, ok := p.Minimum[dep.Name]; ok {
+			if v, err := semver.NewVersion(dep.Version); err == nil && belowMinimum(v.Original(), min) {
+				if err := ctx.exempt(dep, RuleMinimum, fmt.Sprintf("version %s below minimum %s", dep.Version, min)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// CheckResolved evaluate minimum version after version is resolved, version is empty if unknown
+func (ctx *Ctx) CheckResolved(dep *Dependency, version string) error {
+	if ctx.Policy == nil {
+		return nil
+	}
+
+	for _, p := range ctx.Policy.all() {
+		min, ok := p.Minimum[dep.Name]
+		if !ok {
+			continue
+		}
+
+		reason := ""
+		if version == "" {
+			reason = "cannot determine version, minimum is " + min
+		} else if belowMinimum(version, min) {
+			reason = fmt.Sprintf("version %s below minimum %s", version, min)
+		}
+
+		if reason != "" {
+			if err := ctx.exempt(dep, RuleMinimum, reason); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// exempt returns nil if rule is exempted with justification
+func (ctx *Ctx) exempt(dep *Dependency, rule string, reason string) error {
+	if !hasString(dep.Exempt, rule) {
+		return &PolicyError{Rule: rule, Package: dep.Name, Reason: reason}
+	}
+
+	if strings.TrimSpace(dep.Justification) == "" {
+		return &PolicyError{Rule: rule, Package: dep.Name, Reason: reason + ", exempt without justification"}
+	}
+
+	ctx.Warn("policy [%s] exempted for %s: %s (%s)", rule, dep.Name, reason, dep.Justification)
+	return nil
+}
+
+func belowMinimum(version string, min string) bool {
+	v, err1 := semver.NewVersion(version)
+	m, err2 := semver.NewVersion(min)
+	if err1 != nil || err2 != nil {
+		return true
+	}
+
+	return v.LessThan(m)
+}
+
+// noHost is the allowlist of policies which have no host in common, it matches no host
+const noHost = "-"
+
+// intersectHosts returns hosts allowed by both allowlists, empty allows all,
+// the narrower of matched patterns is kept, eg: *.example.com and git.example.com
+func intersectHosts(a []string, b []string) []string {
+	if len(a) == 0 || len(b) == 0 {
+		return append(append([]string{}, a...), b...)
+	}
+
+	result := []string{}
+	for _, x := range a {
+		for _, y := range b {
+			switch {
+			case matchHost([]string{x}, y):
+				result = appendUnique(result, y)
+			case matchHost([]string{y}, x):
+				result = appendUnique(result, x)
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		return []string{noHost}
+	}
+
+	return result
+}
+
+func matchHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, host); ok || pattern == host {
+			return true
+		}
+	}
+
+	return false
+}
+
+// urlHost returns host of repo url, empty for local path
+func urlHost(repo string) string {
+	if m := scpSyntaxRe.FindStringSubmatch(repo); m != nil {
+		return m[2]
+	}
+
+	if u, err := url.Parse(repo); err == nil {
+		return u.Hostname()
+	}
+
+	return ""
+}
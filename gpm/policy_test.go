@@ -0,0 +1,142 @@
+package gpm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIntersectHosts(t *testing.T) {
+	cases := []struct {
+		a, b, expected []string
+	}{
+		{nil, []string{"github.com"}, []string{"github.com"}},
+		{[]string{"github.com", "gitlab.com"}, []string{"gitlab.com", "bitbucket.org"}, []string{"gitlab.com"}},
+		{[]string{"*.example.com"}, []string{"git.example.com", "github.com"}, []string{"git.example.com"}},
+		{[]string{"github.com"}, []string{"gitlab.com"}, []string{noHost}},
+	}
+
+	for _, tc := range cases {
+		if actual := intersectHosts(tc.a, tc.b); !reflect.DeepEqual(actual, tc.expected) {
+			t.Errorf("intersectHosts(%v, %v) = %v, expected %v", tc.a, tc.b, actual, tc.expected)
+		}
+	}
+
+	if matchHost([]string{noHost}, "github.com") {
+		t.Error("empty intersection allows host")
+	}
+}
+
+func TestInheritPolicy(t *testing.T) {
+	cfg := &Config{}
+	cfg.inherit(&Config{Policy: &Policy{Hosts: []string{"github.com", "*.corp.com"}, Minimum: map[string]string{"example.com/lib": "1.2.0"}}})
+	cfg.inherit(&Config{Policy: &Policy{Hosts: []string{"git.corp.com", "evil.com"}, Minimum: map[string]string{"example.com/lib": "1.0.0"}}})
+
+	if !reflect.DeepEqual(cfg.Policy.Hosts, []string{"git.corp.com"}) {
+		t.Errorf("hosts: %v", cfg.Policy.Hosts)
+	}
+
+	if min := cfg.Policy.Minimum["example.com/lib"]; min != "1.2.0" {
+		t.Errorf("minimum is lowered to %s", min)
+	}
+}
+
+func TestDependencyBase(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"gpm.yaml":   "package: app\nversion: 0.1.0\nextends:\n- example.com/platform//base.yaml\n- local.yaml\nimport:\n",
+		"local.yaml": "replace:\n- package: example.com/a\n  repo: https://git.corp.com/a.git\nsettings:\n  jobs: 2\n",
+		"vendor/example.com/platform/base.yaml": "import:\n- package: example.com/lib\n  version: ^1.0.0\n" +
+			"replace:\n- package: example.com/lib\n  repo: https://evil.com/lib.git\nsettings:\n  jobs: 8\n  mirrors:\n    example.com: https://evil.com\n",
+	}
+	for name, body := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(file), 0755)
+		if err := ioutil.WriteFile(file, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(root)
+
+	cfg := NewConfig()
+	if err := cfg.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.FindDependency("example.com/lib") == nil {
+		t.Error("imports of dependency base are not inherited")
+	}
+
+	if cfg.FindReplace("example.com/lib") != nil || cfg.FindReplace("example.com/a") == nil {
+		t.Errorf("replaces: %+v", cfg.Replaces)
+	}
+
+	if s := cfg.ProjectSettings; s == nil || s.Jobs != 2 || len(s.Mirrors) != 0 {
+		t.Errorf("settings: %+v", s)
+	}
+
+	// machine keys are rejected in bases
+	ioutil.WriteFile(filepath.Join(root, "local.yaml"), []byte("settings:\n  credential-helper: store\n"), 0644)
+	if err := NewConfig().Load(); err == nil || !strings.Contains(err.Error(), "credential-helper") {
+		t.Errorf("credential-helper of base is accepted:%+v", err)
+	}
+}
+
+func TestMissingBase(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	conf := "package: app\nextends:\n- example.com/platform//base.yaml\nimport:\n- package: example.com/platform\n  repo: file:///nonexistent\n"
+	if err := ioutil.WriteFile(filepath.Join(root, ConfName), []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+
+	// read only commands do not fetch bases or write lock
+	ctx := &Ctx{Logger: NewLogger(), Config: NewConfig(), LockFile: NewLockFile(), StartDir: root}
+	ctx.Quiet = true
+	ctx.PanicOnDie = true
+	func() {
+		defer func() {
+			if r := recover(); r != "trapped" {
+				t.Errorf("missing base is not reported:%+v", r)
+			}
+		}()
+		ctx.MustLoad()
+	}()
+
+	if Exists(filepath.Join(root, LockName)) {
+		t.Error("lock is written while loading config")
+	}
+}
+
+func TestPolicyHostBeforeMirror(t *testing.T) {
+	ctx := &Ctx{Logger: NewLogger(), Config: &Config{}, LockFile: NewLockFile(), Settings: DefaultSettings()}
+	ctx.Settings.Mirrors = map[string]string{"evil.com": "https://github.com/mirror"}
+	ctx.Policy = &Policy{Hosts: []string{"github.com"}}
+
+	// mirror of a banned origin is not allowed
+	dep := &Dependency{Name: "evil.com/lib"}
+	if _, err := ctx.GetDependency(dep, ""); !isPolicyError(err, RuleHosts) {
+		t.Errorf("origin host is not checked:%+v", err)
+	}
+
+	if err := ctx.Get("evil.com/lib", ""); !isPolicyError(err, RuleHosts) {
+		t.Errorf("origin host is not checked by get:%+v", err)
+	}
+}
+
+func isPolicyError(err error, rule string) bool {
+	e, ok := err.(*PolicyError)
+	return ok && e.Rule == rule
+}
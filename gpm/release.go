@@ -0,0 +1,253 @@
+package gpm
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// ReleaseDir is the dir of release packages, layout is dist/release/<version>/
+const ReleaseDir = "dist/release"
+
+// ChecksumsName is the checksums file of release packages
+const ChecksumsName = "SHA256SUMS"
+
+// releaseDocs are files of project root added to every package
+var releaseDocs = []string{"readme", "license", "licence", "copying", "notice"}
+
+// BumpVersion returns version increased by part: major, minor, patch or prerelease,
+// prerelease increase the number after pre, eg: 1.2.0-rc.1 -> 1.2.0-rc.2, 1.2.0 -> 1.2.1-rc.1
+func BumpVersion(version string, part string, pre string) (string, error) {
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return "", fmt.Errorf("invalid version %s:%+v", version, err)
+	}
+
+	var next semver.Version
+	switch part {
+	case "major":
+		next = v.IncMajor()
+	case "minor":
+		next = v.IncMinor()
+	case "patch":
+		next = v.IncPatch()
+	case "prerelease":
+		n := 1
+		prefix := pre + "."
+		if strings.HasPrefix(v.Prerelease(), prefix) {
+			if i, err := strconv.Atoi(strings.TrimPrefix(v.Prerelease(), prefix)); err == nil {
+				n = i + 1
+			}
+			next = *v
+		} else if v.Prerelease() != "" {
+			next = *v
+		} else {
+			next = v.IncPatch()
+		}
+
+		if next, err = next.SetPrerelease(prefix + strconv.Itoa(n)); err != nil {
+			return "", err
+		}
+
+		if next, err = next.SetMetadata(""); err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("invalid bump %s, should be major, minor, patch or prerelease", part)
+	}
+
+	return next.String(), nil
+}
+
+// ReleaseDocs returns readme, license and notice files of project root
+func ReleaseDocs() []string {
+	files := []string{}
+	infos, _ := ioutil.ReadDir(".")
+	for _, fi := range infos {
+		name := strings.ToLower(fi.Name())
+		for _, prefix := range releaseDocs {
+			if fi.Mode().IsRegular() && strings.HasPrefix(name, prefix) {
+				files = append(files, fi.Name())
+				break
+			}
+		}
+	}
+
+	return files
+}
+
+// PackageArtifact write artifact and files into dir/<target>_<version>_<goos>_<goarch>.tar.gz,
+// or .zip for windows. files are in a dir of the same name, times are BuildTime
+func (ctx *Ctx) PackageArtifact(a *Artifact, version string, dir string, files []string) (string, error) {
+	goos, goarch := splitPlatform(a.Platform)
+	base := fmt.Sprintf("%s_%s_%s_%s", a.Target.Name, version, goos, goarch)
+
+	// binary first, then docs in order
+	entries := append([]string{filepath.Join(ctx.WorkDir, a.Path)}, files...)
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	if goos == "windows" {
+		file := filepath.Join(dir, base+".zip")
+		return file, writeZip(file, base, entries)
+	}
+
+	file := filepath.Join(dir, base+".tar.gz")
+	return file, writeTarGz(file, base, entries)
+}
+
+func writeTarGz(file string, base string, entries []string) error {
+	buf := &bytes.Buffer{}
+	gw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gw)
+	for _, entry := range entries {
+		fi, err := os.Stat(entry)
+		if err != nil {
+			return err
+		}
+
+		// fixed owner and time, package is reproducible
+		hdr := &tar.Header{
+			Name:    base + "/" + filepath.Base(entry),
+			Mode:    int64(fi.Mode().Perm()),
+			Size:    fi.Size(),
+			ModTime: BuildTime(),
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if err := copyInto(tw, entry); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	if err := gw.Close(); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, buf.Bytes(), 0644)
+}
+
+func writeZip(file string, base string, entries []string) error {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, entry := range entries {
+		fi, err := os.Stat(entry)
+		if err != nil {
+			return err
+		}
+
+		hdr := &zip.FileHeader{Name: base + "/" + filepath.Base(entry), Method: zip.Deflate}
+		hdr.Modified = BuildTime()
+		hdr.SetMode(fi.Mode().Perm())
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+
+		if err := copyInto(w, entry); err != nil {
+			return err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, buf.Bytes(), 0644)
+}
+
+func copyInto(w io.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// WriteChecksums write sha256 of files into dir/SHA256SUMS, in format of sha256sum
+func WriteChecksums(dir string, files []string) error {
+	sorted := append([]string{}, files...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return filepath.Base(sorted[i]) < filepath.Base(sorted[j])
+	})
+
+	lines := []string{}
+	for _, file := range sorted {
+		sum, err := HashFile(file)
+		if err != nil {
+			return err
+		}
+
+		lines = append(lines, sum+"  "+filepath.Base(file))
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, ChecksumsName), []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// GitClean returns error if tracked files of project are modified
+func GitClean() error {
+	out, err := exec.Command("git", "status", "--porcelain", "--untracked-files=no").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git status fail:%s", bytes.TrimSpace(out))
+	}
+
+	if len(bytes.TrimSpace(out)) > 0 {
+		return fmt.Errorf("worktree has changes, commit or stash them first:\n%s", bytes.TrimRight(out, "\n"))
+	}
+
+	return nil
+}
+
+// GitTagExists returns true if tag exists in project
+func GitTagExists(tag string) bool {
+	return exec.Command("git", "rev-parse", "-q", "--verify", "refs/tags/"+tag).Run() == nil
+}
+
+// runGit run git command in project, output is returned in error
+func runGit(args ...string) error {
+	out, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git %s fail:%s", args[0], bytes.TrimSpace(out))
+	}
+
+	return nil
+}
+
+// CommitVersion commit the bumped version of gpm.yaml
+func CommitVersion(tag string) error {
+	return runGit("commit", "-m", "Release "+tag, "--", ConfName)
+}
+
+// ResetVersion drop the commit of CommitVersion, gpm.yaml is restored
+func ResetVersion() error {
+	return runGit("reset", "--keep", "HEAD~1")
+}
+
+// TagRelease create annotated tag of HEAD
+func TagRelease(tag string) error {
+	return runGit("tag", "-a", tag, "-m", "Release "+tag)
+}
@@ -0,0 +1,200 @@
+package gpm
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBumpVersion(t *testing.T) {
+	cases := []struct {
+		version, part, pre, expected string
+	}{
+		{"1.2.3", "major", "", "2.0.0"},
+		{"1.2.3", "minor", "", "1.3.0"},
+		{"1.2.3", "patch", "", "1.2.4"},
+		{"v1.2.3", "patch", "", "1.2.4"},
+		{"1.2.0-rc.1", "patch", "", "1.2.0"},
+		{"1.2.0", "prerelease", "rc", "1.2.1-rc.1"},
+		{"1.2.0-rc.1", "prerelease", "rc", "1.2.0-rc.2"},
+		{"1.2.0-rc.9", "prerelease", "rc", "1.2.0-rc.10"},
+		{"1.2.0-beta.3", "prerelease", "rc", "1.2.0-rc.1"},
+		{"1.2.0-rc.x", "prerelease", "rc", "1.2.0-rc.1"},
+		{"1.2.0+build.5", "prerelease", "rc", "1.2.1-rc.1"},
+		{"1.2.3", "build", "", ""},
+		{"latest", "patch", "", ""},
+	}
+
+	for _, tc := range cases {
+		actual, err := BumpVersion(tc.version, tc.part, tc.pre)
+		if tc.expected == "" {
+			if err == nil {
+				t.Errorf("BumpVersion(%s, %s) = %s, expected error", tc.version, tc.part, actual)
+			}
+			continue
+		}
+
+		if err != nil || actual != tc.expected {
+			t.Errorf("BumpVersion(%s, %s, %s) = %s, %v, expected %s", tc.version, tc.part, tc.pre, actual, err, tc.expected)
+		}
+	}
+}
+
+func TestReleaseDocs(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(dir)
+	writeTree(t, dir, map[string]string{
+		"README.md":     "readme",
+		"LICENSE":       "license",
+		"NOTICE.txt":    "notice",
+		"main.go":       "package main\n",
+		"docs/readme":   "nested",
+		"license/a.txt": "dir",
+	})
+
+	expected := []string{"LICENSE", "NOTICE.txt", "README.md"}
+	if actual := ReleaseDocs(); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("ReleaseDocs() = %v, expected %v", actual, expected)
+	}
+}
+
+func TestPackageArtifact(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	os.Setenv("SOURCE_DATE_EPOCH", "1500000000")
+	defer os.Unsetenv("SOURCE_DATE_EPOCH")
+
+	writeTree(t, dir, map[string]string{
+		"dist/app/linux_amd64/app":       "elf",
+		"dist/app/windows_amd64/app.exe": "pe",
+		"LICENSE":                        "license",
+	})
+
+	ctx := &Ctx{Logger: NewLogger(), WorkDir: dir}
+	target := &Target{Name: "app"}
+	out := filepath.Join(dir, ReleaseDir, "1.0.0")
+	docs := []string{filepath.Join(dir, "LICENSE")}
+
+	cases := []struct {
+		platform string
+		expected string
+	}{
+		{"linux/amd64", "app_1.0.0_linux_amd64.tar.gz"},
+		{"windows/amd64", "app_1.0.0_windows_amd64.zip"},
+	}
+
+	files := []string{}
+	for _, tc := range cases {
+		a := &Artifact{Target: target, Platform: tc.platform, Path: target.ArtifactPath(tc.platform)}
+		file, err := ctx.PackageArtifact(a, "1.0.0", out, docs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if filepath.Base(file) != tc.expected {
+			t.Errorf("package %s, expected %s", filepath.Base(file), tc.expected)
+		}
+
+		first, _ := HashFile(file)
+		ctx.PackageArtifact(a, "1.0.0", out, docs)
+		if second, _ := HashFile(file); first != second {
+			t.Errorf("%s is not reproducible", tc.expected)
+		}
+
+		files = append(files, file)
+	}
+
+	base := "app_1.0.0_linux_amd64/"
+	expected := map[string]string{base + "app": "elf", base + "LICENSE": "license"}
+	if actual := readTarGz(t, files[0]); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("tar.gz entries %v, expected %v", actual, expected)
+	}
+
+	base = "app_1.0.0_windows_amd64/"
+	expected = map[string]string{base + "app.exe": "pe", base + "LICENSE": "license"}
+	if actual := readZip(t, files[1]); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("zip entries %v, expected %v", actual, expected)
+	}
+
+	// sorted by name, in format of sha256sum
+	if err := WriteChecksums(out, []string{files[1], files[0]}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(readString(t, filepath.Join(out, ChecksumsName))), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("checksums %v", lines)
+	}
+
+	for i, file := range files {
+		data, _ := ioutil.ReadFile(file)
+		if expected := sha256Hex(data) + "  " + filepath.Base(file); lines[i] != expected {
+			t.Errorf("checksum %s, expected %s", lines[i], expected)
+		}
+	}
+}
+
+func readTarGz(t *testing.T, file string) map[string]string {
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(map[string]string)
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+
+		if !hdr.ModTime.Equal(time.Unix(1500000000, 0)) {
+			t.Errorf("%s time %v", hdr.Name, hdr.ModTime)
+		}
+
+		data, _ := ioutil.ReadAll(tr)
+		result[hdr.Name] = string(data)
+	}
+
+	return result
+}
+
+func readZip(t *testing.T, file string) map[string]string {
+	zr, err := zip.OpenReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	result := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, _ := ioutil.ReadAll(r)
+		r.Close()
+		result[f.Name] = string(data)
+	}
+
+	return result
+}
@@ -0,0 +1,404 @@
+package gpm
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// sbom formats
+const (
+	SbomCycloneDX = "cyclonedx-json"
+	SbomSpdxJSON  = "spdx-json"
+	SbomSpdxTag   = "spdx-tag"
+)
+
+// SbomPackage is a component in sbom
+type SbomPackage struct {
+	Name        string
+	Version     string // semver tag, empty if not tagged
+	Purl        string
+	Hash        string // sha256 of vendor dir
+	Commit      string // vcs revision, empty for archive
+	ArchiveHash string // sha256 of archive
+	License     string // SPDX expression, empty if not found
+	Download    string
+	DependsOn   []string // names of packages
+}
+
+// Sbom is the software bill of materials built from gpm.lock and vendor
+type Sbom struct {
+	Tool     string
+	Serial   string // uuid of project, version, gpm.lock and created time
+	Created  time.Time
+	Root     *SbomPackage
+	Packages []*SbomPackage
+}
+
+// NewSbom build sbom from config, lock and vendor dir, no network is used
+func (ctx *Ctx) NewSbom(tool string) (*Sbom, error) {
+	lockHash, err := LockHash()
+	if err != nil {
+		return nil, fmt.Errorf("read lock fail, run gpm install first:%+v", err)
+	}
+
+	// unique per document, yet reproducible with SOURCE_DATE_EPOCH
+	created := BuildTime()
+	serial := hashUUID(strings.Join([]string{ctx.Name, ctx.Version, lockHash, created.Format(time.RFC3339Nano)}, "\x00"))
+	sbom := &Sbom{Tool: tool, Serial: serial, Created: created}
+	sbom.Root = &SbomPackage{
+		Name:     ctx.Name,
+		Version:  ctx.Version,
+		Purl:     Purl(ctx.Name, ctx.Version),
+		License:  ctx.License,
+		Download: ctx.Home,
+	}
+
+	for _, dep := range ctx.Imports {
+		lock := ctx.LockFile.Find(dep.Name)
+		if lock == nil {
+			return nil, fmt.Errorf("dependency not locked:%+v", dep.Name)
+		}
+
+		// reversion is a commit or sha256 of archive, not a version
+		version := lock.Version
+		if version == "" && lock.Archive != "" {
+			version = ArchiveVersion(lock.Archive)
+		}
+
+		dir := filepath.Join("vendor", dep.Name)
+		pkg := &SbomPackage{
+			Name:     dep.Name,
+			Version:  version,
+			Purl:     Purl(dep.Name, version),
+			Hash:     lock.Hash,
+			Commit:   lock.Reversion,
+			License:  LicenseExpression(DetectLicenses(dir)),
+			Download: ctx.RemoteOf(dep),
+		}
+
+		if lock.Archive != "" {
+			pkg.Download = lock.Archive
+			pkg.Commit = ""
+			pkg.ArchiveHash = lock.Reversion
+		}
+
+		if lock.IsReplaced() {
+			pkg.Download = lock.Replace
+		}
+
+		sbom.Root.DependsOn = append(sbom.Root.DependsOn, dep.Name)
+		sbom.Packages = append(sbom.Packages, pkg)
+	}
+
+	// vendored package may declare its own imports
+	for _, pkg := range sbom.Packages {
+		for _, name := range vendorImports(filepath.Join("vendor", pkg.Name)) {
+			if ctx.LockFile.Find(name) != nil && name != pkg.Name {
+				pkg.DependsOn = append(pkg.DependsOn, name)
+			}
+		}
+	}
+
+	return sbom, nil
+}
+
+// Write write sbom in format
+func (s *Sbom) Write(w io.Writer, format string) error {
+	switch format {
+	case SbomCycloneDX:
+		return s.writeCycloneDX(w)
+	case SbomSpdxJSON:
+		return s.writeSpdxJSON(w)
+	case SbomSpdxTag:
+		return s.writeSpdxTag(w)
+	}
+
+	return fmt.Errorf("unknown sbom format:%+v", format)
+}
+
+func (s *Sbom) writeCycloneDX(w io.Writer) error {
+	component := func(typ string, pkg *SbomPackage) map[string]interface{} {
+		c := map[string]interface{}{
+			"type":    typ,
+			"bom-ref": pkg.Purl,
+			"name":    pkg.Name,
+			"purl":    pkg.Purl,
+		}
+
+		if pkg.Version != "" {
+			c["version"] = pkg.Version
+		}
+
+		if pkg.Hash != "" {
+			c["hashes"] = []map[string]string{{"alg": "SHA-256", "content": pkg.Hash}}
+		}
+
+		if pkg.License != "" && !strings.Contains(pkg.License, LicenseUnknown) {
+			if strings.Contains(pkg.License, " ") {
+				c["licenses"] = []map[string]string{{"expression": pkg.License}}
+			} else {
+				c["licenses"] = []map[string]interface{}{{"license": map[string]string{"id": pkg.License}}}
+			}
+		}
+
+		switch {
+		case pkg.ArchiveHash != "":
+			c["externalReferences"] = []map[string]interface{}{{
+				"type":   "distribution",
+				"url":    pkg.Download,
+				"hashes": []map[string]string{{"alg": "SHA-256", "content": pkg.ArchiveHash}},
+			}}
+		case pkg.Commit != "":
+			c["externalReferences"] = []map[string]string{{"type": "vcs", "url": pkg.Download, "comment": "revision " + pkg.Commit}}
+		case pkg.Download != "":
+			c["externalReferences"] = []map[string]string{{"type": "distribution", "url": pkg.Download}}
+		}
+
+		return c
+	}
+
+	refs := map[string]string{s.Root.Name: s.Root.Purl}
+	components := []interface{}{}
+	for _, pkg := range s.Packages {
+		refs[pkg.Name] = pkg.Purl
+		components = append(components, component("library", pkg))
+	}
+
+	dependencies := []interface{}{}
+	for _, pkg := range append([]*SbomPackage{s.Root}, s.Packages...) {
+		dependsOn := []string{}
+		for _, name := range pkg.DependsOn {
+			dependsOn = append(dependsOn, refs[name])
+		}
+
+		dependencies = append(dependencies, map[string]interface{}{"ref": pkg.Purl, "dependsOn": dependsOn})
+	}
+
+	doc := map[string]interface{}{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.4",
+		"serialNumber": "urn:uuid:" + s.Serial,
+		"version":      1,
+		"metadata": map[string]interface{}{
+			"timestamp": s.Created.Format(time.RFC3339),
+			"tools":     []map[string]string{{"name": "gpm", "version": s.Tool}},
+			"component": component("application", s.Root),
+		},
+		"components":   components,
+		"dependencies": dependencies,
+	}
+
+	return writeJSON(w, doc)
+}
+
+func (s *Sbom) writeSpdxJSON(w io.Writer) error {
+	pkgs := []interface{}{}
+	for _, pkg := range append([]*SbomPackage{s.Root}, s.Packages...) {
+		p := map[string]interface{}{
+			"name":             pkg.Name,
+			"SPDXID":           spdxID(pkg.Name),
+			"downloadLocation": spdxValue(pkg.Download),
+			"filesAnalyzed":    false,
+			"licenseConcluded": "NOASSERTION",
+			"licenseDeclared":  spdxLicense(pkg.License),
+			"copyrightText":    "NOASSERTION",
+			"externalRefs": []map[string]string{{
+				"referenceCategory": "PACKAGE-MANAGER",
+				"referenceType":     "purl",
+				"referenceLocator":  pkg.Purl,
+			}},
+		}
+
+		if pkg.Version != "" {
+			p["versionInfo"] = pkg.Version
+		}
+
+		if pkg.Hash != "" {
+			p["checksums"] = []map[string]string{{"algorithm": "SHA256", "checksumValue": pkg.Hash}}
+		}
+
+		if info := pkg.sourceInfo(); info != "" {
+			p["sourceInfo"] = info
+		}
+
+		pkgs = append(pkgs, p)
+	}
+
+	relationships := []interface{}{}
+	for _, r := range s.relationships() {
+		relationships = append(relationships, map[string]string{
+			"spdxElementId":      r[0],
+			"relationshipType":   r[1],
+			"relatedSpdxElement": r[2],
+		})
+	}
+
+	doc := map[string]interface{}{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              s.Root.Name,
+		"documentNamespace": s.namespace(),
+		"creationInfo": map[string]interface{}{
+			"created":  s.Created.Format(time.RFC3339),
+			"creators": []string{"Tool: gpm-" + s.Tool},
+		},
+		"packages":      pkgs,
+		"relationships": relationships,
+	}
+
+	return writeJSON(w, doc)
+}
+
+func (s *Sbom) writeSpdxTag(w io.Writer) error {
+	fmt.Fprintf(w, "SPDXVersion: SPDX-2.3\n")
+	fmt.Fprintf(w, "DataLicense: CC0-1.0\n")
+	fmt.Fprintf(w, "SPDXID: SPDXRef-DOCUMENT\n")
+	fmt.Fprintf(w, "DocumentName: %s\n", s.Root.Name)
+	fmt.Fprintf(w, "DocumentNamespace: %s\n", s.namespace())
+	fmt.Fprintf(w, "Creator: Tool: gpm-%s\n", s.Tool)
+	fmt.Fprintf(w, "Created: %s\n", s.Created.Format(time.RFC3339))
+
+	for _, pkg := range append([]*SbomPackage{s.Root}, s.Packages...) {
+		fmt.Fprintf(w, "\nPackageName: %s\n", pkg.Name)
+		fmt.Fprintf(w, "SPDXID: %s\n", spdxID(pkg.Name))
+		if pkg.Version != "" {
+			fmt.Fprintf(w, "PackageVersion: %s\n", pkg.Version)
+		}
+		fmt.Fprintf(w, "PackageDownloadLocation: %s\n", spdxValue(pkg.Download))
+		fmt.Fprintf(w, "FilesAnalyzed: false\n")
+		if pkg.Hash != "" {
+			fmt.Fprintf(w, "PackageChecksum: SHA256: %s\n", pkg.Hash)
+		}
+		if info := pkg.sourceInfo(); info != "" {
+			fmt.Fprintf(w, "PackageSourceInfo: %s\n", info)
+		}
+		fmt.Fprintf(w, "PackageLicenseConcluded: NOASSERTION\n")
+		fmt.Fprintf(w, "PackageLicenseDeclared: %s\n", spdxLicense(pkg.License))
+		fmt.Fprintf(w, "PackageCopyrightText: NOASSERTION\n")
+		fmt.Fprintf(w, "ExternalRef: PACKAGE-MANAGER purl %s\n", pkg.Purl)
+	}
+
+	fmt.Fprintln(w)
+	for _, r := range s.relationships() {
+		fmt.Fprintf(w, "Relationship: %s %s %s\n", r[0], r[1], r[2])
+	}
+
+	return nil
+}
+
+// sourceInfo describes the revision or archive the package is built from
+func (pkg *SbomPackage) sourceInfo() string {
+	switch {
+	case pkg.ArchiveHash != "":
+		return "archive sha256 " + pkg.ArchiveHash
+	case pkg.Commit != "":
+		return "revision " + pkg.Commit
+	}
+
+	return ""
+}
+
+// relationships returns [element, type, related] triples
+func (s *Sbom) relationships() [][3]string {
+	result := [][3]string{{"SPDXRef-DOCUMENT", "DESCRIBES", spdxID(s.Root.Name)}}
+	for _, pkg := range append([]*SbomPackage{s.Root}, s.Packages...) {
+		for _, name := range pkg.DependsOn {
+			result = append(result, [3]string{spdxID(pkg.Name), "DEPENDS_ON", spdxID(name)})
+		}
+	}
+
+	return result
+}
+
+func (s *Sbom) namespace() string {
+	return "https://spdx.org/spdxdocs/" + strings.Replace(s.Root.Name, "/", "-", -1) + "-" + s.Serial
+}
+
+// Purl returns golang package url
+func Purl(name string, version string) string {
+	purl := "pkg:golang/" + name
+	if version != "" {
+		purl += "@" + version
+	}
+
+	return purl
+}
+
+// BuildTime returns SOURCE_DATE_EPOCH if set, otherwise now
+func BuildTime() time.Time {
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		if sec, err := strconv.ParseInt(epoch, 10, 64); err == nil {
+			return time.Unix(sec, 0).UTC()
+		}
+	}
+
+	return time.Now().UTC()
+}
+
+var spdxInvalid = regexp.MustCompile(`[^a-zA-Z0-9.-]+`)
+
+func spdxID(name string) string {
+	return "SPDXRef-Package-" + spdxInvalid.ReplaceAllString(name, "-")
+}
+
+func spdxValue(value string) string {
+	if value == "" {
+		return "NOASSERTION"
+	}
+
+	return value
+}
+
+func spdxLicense(license string) string {
+	if license == "" || strings.Contains(license, LicenseUnknown) {
+		return "NOASSERTION"
+	}
+
+	return license
+}
+
+// hashUUID returns a stable uuid derived from s
+func hashUUID(s string) string {
+	h := sha1.Sum([]byte(s))
+	h[6] = (h[6] & 0x0f) | 0x50
+	h[8] = (h[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16])
+}
+
+// vendorImports returns imports declared in gpm.yaml of vendored package
+func vendorImports(dir string) []string {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ConfName))
+	if err != nil {
+		return nil
+	}
+
+	cfg := NewConfig()
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil
+	}
+
+	names := []string{}
+	for _, dep := range cfg.Imports {
+		names = append(names, dep.Name)
+	}
+
+	return names
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
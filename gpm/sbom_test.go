@@ -0,0 +1,269 @@
+package gpm
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPurl(t *testing.T) {
+	cases := []struct {
+		name, version, expected string
+	}{
+		{"github.com/pkg/errors", "v0.8.1", "pkg:golang/github.com/pkg/errors@v0.8.1"},
+		{"example.com/lib", "", "pkg:golang/example.com/lib"},
+	}
+
+	for _, tc := range cases {
+		if actual := Purl(tc.name, tc.version); actual != tc.expected {
+			t.Errorf("Purl(%s, %s) = %s, expected %s", tc.name, tc.version, actual, tc.expected)
+		}
+	}
+}
+
+func TestNewSbom(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	wd, _ := os.Getwd()
+	defer os.Chdir(wd)
+	os.Chdir(root)
+
+	if err := ioutil.WriteFile(LockName, []byte("imports: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := "https://example.com/lib-1.2.3.tar.gz"
+	sha := sha256Hex([]byte(archive))
+	commit := "0123456789abcdef0123456789abcdef01234567"
+	ctx := &Ctx{Logger: NewLogger(), Config: &Config{Name: "example.com/app", Version: "1.0.0"}, LockFile: NewLockFile(), Settings: DefaultSettings()}
+	ctx.Imports = []*Dependency{{Name: "example.com/lib", Archive: archive, Sha256: sha}, {Name: "github.com/pkg/errors"}, {Name: "github.com/pkg/untagged"}}
+	ctx.LockFile.Imports = []*Lock{
+		{Name: "example.com/lib", Archive: archive, Reversion: sha},
+		{Name: "github.com/pkg/errors", Reversion: commit, Version: "v0.8.1"},
+		{Name: "github.com/pkg/untagged", Reversion: commit},
+	}
+
+	sbom, err := ctx.NewSbom("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []SbomPackage{
+		{Name: "example.com/lib", Version: "1.2.3", Purl: "pkg:golang/example.com/lib@1.2.3", ArchiveHash: sha, Download: archive},
+		{Name: "github.com/pkg/errors", Version: "v0.8.1", Purl: "pkg:golang/github.com/pkg/errors@v0.8.1", Commit: commit, Download: "https://github.com/pkg/errors"},
+		{Name: "github.com/pkg/untagged", Purl: "pkg:golang/github.com/pkg/untagged", Commit: commit, Download: "https://github.com/pkg/untagged"},
+	}
+	for i, pkg := range sbom.Packages {
+		e := expected[i]
+		if pkg.Version != e.Version || pkg.Purl != e.Purl || pkg.Commit != e.Commit || pkg.ArchiveHash != e.ArchiveHash || pkg.Download != e.Download {
+			t.Errorf("package %+v, expected %+v", pkg, e)
+		}
+	}
+
+	// revision and archive hash are emitted as vcs ref and hash, never as version
+	for _, format := range []string{SbomCycloneDX, SbomSpdxJSON, SbomSpdxTag} {
+		buf := &bytes.Buffer{}
+		if err := sbom.Write(buf, format); err != nil {
+			t.Fatalf("%s: %+v", format, err)
+		}
+
+		out := buf.String()
+		if format != SbomSpdxTag && !json.Valid(buf.Bytes()) {
+			t.Errorf("%s: invalid json", format)
+		}
+
+		if strings.Contains(out, "@"+sha) || strings.Contains(out, "@"+commit) {
+			t.Errorf("%s: purl with revision:\n%s", format, out)
+		}
+
+		if !strings.Contains(out, sha) || !strings.Contains(out, "revision "+commit) {
+			t.Errorf("%s: revision or archive hash missing:\n%s", format, out)
+		}
+	}
+}
+
+func testSbom() *Sbom {
+	return &Sbom{
+		Tool:    "1.0",
+		Serial:  hashUUID("app"),
+		Created: time.Unix(1500000000, 0).UTC(),
+		Root:    &SbomPackage{Name: "example.com/app", Version: "1.0.0", Purl: "pkg:golang/example.com/app@1.0.0", License: "MIT", DependsOn: []string{"example.com/lib", "github.com/a/b"}},
+		Packages: []*SbomPackage{
+			{Name: "example.com/lib", Version: "1.2.3", Purl: "pkg:golang/example.com/lib@1.2.3", Hash: "h1", ArchiveHash: "a1", License: "MIT OR Apache-2.0", Download: "https://example.com/lib-1.2.3.tar.gz"},
+			{Name: "github.com/a/b", Purl: "pkg:golang/github.com/a/b", Hash: "h2", Commit: "c2", License: LicenseUnknown, Download: "https://github.com/a/b", DependsOn: []string{"example.com/lib"}},
+		},
+	}
+}
+
+func TestSbomSpdxTag(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := testSbom().Write(buf, SbomSpdxTag); err != nil {
+		t.Fatal(err)
+	}
+
+	serial := hashUUID("app")
+	expected := `SPDXVersion: SPDX-2.3
+DataLicense: CC0-1.0
+SPDXID: SPDXRef-DOCUMENT
+DocumentName: example.com/app
+DocumentNamespace: https://spdx.org/spdxdocs/example.com-app-` + serial + `
+Creator: Tool: gpm-1.0
+Created: 2017-07-14T02:40:00Z
+
+PackageName: example.com/app
+SPDXID: SPDXRef-Package-example.com-app
+PackageVersion: 1.0.0
+PackageDownloadLocation: NOASSERTION
+FilesAnalyzed: false
+PackageLicenseConcluded: NOASSERTION
+PackageLicenseDeclared: MIT
+PackageCopyrightText: NOASSERTION
+ExternalRef: PACKAGE-MANAGER purl pkg:golang/example.com/app@1.0.0
+
+PackageName: example.com/lib
+SPDXID: SPDXRef-Package-example.com-lib
+PackageVersion: 1.2.3
+PackageDownloadLocation: https://example.com/lib-1.2.3.tar.gz
+FilesAnalyzed: false
+PackageChecksum: SHA256: h1
+PackageSourceInfo: archive sha256 a1
+PackageLicenseConcluded: NOASSERTION
+PackageLicenseDeclared: MIT OR Apache-2.0
+PackageCopyrightText: NOASSERTION
+ExternalRef: PACKAGE-MANAGER purl pkg:golang/example.com/lib@1.2.3
+
+PackageName: github.com/a/b
+SPDXID: SPDXRef-Package-github.com-a-b
+PackageDownloadLocation: https://github.com/a/b
+FilesAnalyzed: false
+PackageChecksum: SHA256: h2
+PackageSourceInfo: revision c2
+PackageLicenseConcluded: NOASSERTION
+PackageLicenseDeclared: NOASSERTION
+PackageCopyrightText: NOASSERTION
+ExternalRef: PACKAGE-MANAGER purl pkg:golang/github.com/a/b
+
+Relationship: SPDXRef-DOCUMENT DESCRIBES SPDXRef-Package-example.com-app
+Relationship: SPDXRef-Package-example.com-app DEPENDS_ON SPDXRef-Package-example.com-lib
+Relationship: SPDXRef-Package-example.com-app DEPENDS_ON SPDXRef-Package-github.com-a-b
+Relationship: SPDXRef-Package-github.com-a-b DEPENDS_ON SPDXRef-Package-example.com-lib
+`
+
+	if actual := buf.String(); actual != expected {
+		t.Errorf("spdx tag:\n%s\nexpected:\n%s", actual, expected)
+	}
+}
+
+func TestSbomJSON(t *testing.T) {
+	s := testSbom()
+	buf := &bytes.Buffer{}
+	if err := s.Write(buf, SbomCycloneDX); err != nil {
+		t.Fatal(err)
+	}
+
+	bom := struct {
+		SerialNumber string
+		Metadata     struct {
+			Timestamp string
+			Component struct {
+				Purl     string
+				Licenses []struct{ License struct{ ID string } }
+			}
+		}
+		Components []struct {
+			Name, Version, Purl string
+			Hashes              []struct{ Alg, Content string }
+			Licenses            []struct{ Expression string }
+			ExternalReferences  []struct {
+				Type, URL, Comment string
+				Hashes             []struct{ Alg, Content string }
+			}
+		}
+		Dependencies []struct {
+			Ref       string
+			DependsOn []string
+		}
+	}{}
+
+	if err := json.Unmarshal(buf.Bytes(), &bom); err != nil {
+		t.Fatal(err)
+	}
+
+	if bom.SerialNumber != "urn:uuid:"+s.Serial || bom.Metadata.Timestamp != "2017-07-14T02:40:00Z" || bom.Metadata.Component.Purl != s.Root.Purl {
+		t.Errorf("metadata %+v", bom)
+	}
+
+	if licenses := bom.Metadata.Component.Licenses; len(licenses) != 1 || licenses[0].License.ID != "MIT" {
+		t.Errorf("root licenses %+v", licenses)
+	}
+
+	if len(bom.Components) != 2 {
+		t.Fatalf("components %+v", bom.Components)
+	}
+
+	lib, b := bom.Components[0], bom.Components[1]
+	if lib.Version != "1.2.3" || len(lib.Licenses) != 1 || lib.Licenses[0].Expression != "MIT OR Apache-2.0" || lib.Hashes[0].Content != "h1" {
+		t.Errorf("component %+v", lib)
+	}
+
+	if refs := lib.ExternalReferences; len(refs) != 1 || refs[0].Type != "distribution" || refs[0].Hashes[0].Content != "a1" {
+		t.Errorf("archive references %+v", refs)
+	}
+
+	if refs := b.ExternalReferences; b.Version != "" || len(b.Licenses) != 0 || len(refs) != 1 || refs[0].Type != "vcs" || refs[0].Comment != "revision c2" {
+		t.Errorf("component %+v", b)
+	}
+
+	if len(bom.Dependencies) != 3 || bom.Dependencies[2].Ref != b.Purl || len(bom.Dependencies[2].DependsOn) != 1 || bom.Dependencies[2].DependsOn[0] != lib.Purl {
+		t.Errorf("dependencies %+v", bom.Dependencies)
+	}
+
+	buf.Reset()
+	if err := s.Write(buf, SbomSpdxJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	doc := struct {
+		DocumentNamespace string
+		Packages          []struct {
+			SPDXID, VersionInfo, LicenseDeclared, SourceInfo string
+		}
+		Relationships []struct {
+			SpdxElementID, RelationshipType, RelatedSpdxElement string
+		}
+	}{}
+
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Packages) != 3 || doc.Packages[1].SourceInfo != "archive sha256 a1" || doc.Packages[2].LicenseDeclared != "NOASSERTION" || doc.Packages[2].VersionInfo != "" {
+		t.Errorf("spdx packages %+v", doc.Packages)
+	}
+
+	if len(doc.Relationships) != 4 || doc.Relationships[3].SpdxElementID != "SPDXRef-Package-github.com-a-b" || doc.Relationships[3].RelatedSpdxElement != "SPDXRef-Package-example.com-lib" {
+		t.Errorf("spdx relationships %+v", doc.Relationships)
+	}
+
+	if err := s.Write(buf, "xml"); err == nil {
+		t.Error("unknown format is written")
+	}
+}
+
+func TestHashUUID(t *testing.T) {
+	uuid := hashUUID("app")
+	if uuid != hashUUID("app") || uuid == hashUUID("app2") {
+		t.Errorf("hashUUID is not stable")
+	}
+
+	// version 5 and RFC 4122 variant
+	parts := strings.Split(uuid, "-")
+	if len(parts) != 5 || len(uuid) != 36 || parts[2][0] != '5' || !strings.ContainsAny(parts[3][:1], "89ab") {
+		t.Errorf("hashUUID(app) = %s", uuid)
+	}
+}
@@ -0,0 +1,83 @@
+package gpm
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Script is a command of scripts section in gpm.yaml, run by gpm run
+type Script struct {
+	Desc string   `yaml:"description,omitempty"`
+	Run  string   `yaml:"run,omitempty"`  // shell commands, args of gpm run are "$@"
+	Deps []string `yaml:"deps,omitempty"` // scripts run before this one
+}
+
+// ScriptNames returns sorted names of scripts
+func (cfg *Config) ScriptNames() []string {
+	names := []string{}
+	for name := range cfg.Scripts {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// ScriptOrder returns scripts to run for name, deps first, each script runs once
+func (cfg *Config) ScriptOrder(name string) ([]string, error) {
+	order := []string{}
+	done := make(map[string]bool)
+	visiting := []string{}
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		if done[name] {
+			return nil
+		}
+
+		for i, v := range visiting {
+			if v == name {
+				return fmt.Errorf("script cycle %s", strings.Join(append(visiting[i:], name), " -> "))
+			}
+		}
+
+		script, ok := cfg.Scripts[name]
+		if !ok || script == nil {
+			if len(visiting) > 0 {
+				return fmt.Errorf("script %s needed by %s not exists", name, visiting[len(visiting)-1])
+			}
+			return fmt.Errorf("script %s not exists", name)
+		}
+
+		visiting = append(visiting, name)
+		for _, dep := range script.Deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		visiting = visiting[:len(visiting)-1]
+
+		done[name] = true
+		order = append(order, name)
+		return nil
+	}
+
+	if err := visit(name); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// Command returns shell command of script, args are positional parameters
+func (s *Script) Command(name string, args []string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", strings.Join(append([]string{s.Run}, args...), " "))
+	}
+
+	// name is $0, stop at the first failed command
+	return exec.Command("sh", append([]string{"-e", "-c", s.Run, name}, args...)...)
+}
@@ -0,0 +1,82 @@
+package gpm
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestScriptOrder(t *testing.T) {
+	cfg := &Config{Scripts: map[string]*Script{
+		"gen":     {Run: "go generate ./..."},
+		"lint":    {Run: "golint", Deps: []string{"gen"}},
+		"test":    {Run: "go test", Deps: []string{"gen", "lint"}},
+		"ci":      {Deps: []string{"lint", "test", "gen"}},
+		"a":       {Deps: []string{"b"}},
+		"b":       {Deps: []string{"c"}},
+		"c":       {Deps: []string{"a"}},
+		"self":    {Deps: []string{"self"}},
+		"broken":  {Deps: []string{"gen", "missing"}},
+		"nothing": nil,
+	}}
+
+	cases := []struct {
+		name     string
+		expected string
+		err      string
+	}{
+		{"gen", "gen", ""},
+		{"test", "gen lint test", ""},
+		{"ci", "gen lint test ci", ""},
+		{"a", "", "script cycle a -> b -> c -> a"},
+		{"self", "", "script cycle self -> self"},
+		{"broken", "", "script missing needed by broken not exists"},
+		{"missing", "", "script missing not exists"},
+		{"nothing", "", "script nothing not exists"},
+	}
+
+	for _, tc := range cases {
+		order, err := cfg.ScriptOrder(tc.name)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("ScriptOrder(%s) error %v, expected %s", tc.name, err, tc.err)
+			}
+			continue
+		}
+
+		if err != nil || strings.Join(order, " ") != tc.expected {
+			t.Errorf("ScriptOrder(%s) = %v, %v, expected %s", tc.name, order, err, tc.expected)
+		}
+	}
+
+	expected := []string{"a", "b", "broken", "c", "ci", "gen", "lint", "nothing", "self", "test"}
+	if names := cfg.ScriptNames(); !reflect.DeepEqual(names, expected) {
+		t.Errorf("ScriptNames() = %v, expected %v", names, expected)
+	}
+}
+
+func TestScriptCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("script runs by sh")
+	}
+
+	cases := []struct {
+		run      string
+		args     []string
+		expected string
+		fail     bool
+	}{
+		{`echo "$0" "$@"`, []string{"a b", "c"}, "hello a b c\n", false},
+		{`echo $#`, nil, "0\n", false},
+		{"false\necho after", nil, "", true},
+	}
+
+	for _, tc := range cases {
+		s := &Script{Run: tc.run}
+		out, err := s.Command("hello", tc.args).Output()
+		if (err != nil) != tc.fail || string(out) != tc.expected {
+			t.Errorf("run %q = %q, %v, expected %q", tc.run, out, err, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package gpm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cgi"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver"
+)
+
+// DefaultRefresh is the interval of rescanning cache of gpm serve
+const DefaultRefresh = time.Minute
+
+// Server share the cache as go-get, git smart http and optional GOPROXY mirror.
+// requests are answered from the index of cache, which is rebuilt every Refresh
+type Server struct {
+	sync.Mutex
+	*Logger
+	CacheDir string
+	Proxy    bool              // serve GOPROXY protocol
+	Refresh  time.Duration     // interval of rescanning cache, never if 0
+	repos    map[string]string // package path -> cache key
+}
+
+// NewServer create server for cache dir
+func NewServer(cacheDir string, logger *Logger) *Server {
+	return &Server{CacheDir: cacheDir, Logger: logger, Refresh: DefaultRefresh, repos: make(map[string]string)}
+}
+
+// Scan find all git repos in cache, package path is parsed from remote url
+func (s *Server) Scan() error {
+	infos, err := ioutil.ReadDir(s.CacheDir)
+	if err != nil {
+		return err
+	}
+
+	repos := make(map[string]string)
+	for _, fi := range infos {
+		if !fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+
+		dir := filepath.Join(s.CacheDir, fi.Name())
+		if !Exists(filepath.Join(dir, ".git")) {
+			continue
+		}
+
+		out, err := exec.Command("git", "-C", dir, "config", "--get", "remote.origin.url").Output()
+		if err != nil {
+			continue
+		}
+
+		if pkg := PackagePath(strings.TrimSpace(string(out))); pkg != "" {
+			repos[pkg] = fi.Name()
+		}
+	}
+
+	s.Lock()
+	s.repos = repos
+	s.Unlock()
+	return nil
+}
+
+// Packages returns all package paths served
+func (s *Server) Packages() []string {
+	s.Lock()
+	defer s.Unlock()
+	result := []string{}
+	for pkg := range s.repos {
+		result = append(result, pkg)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// lookup find the repo which contains path in index, returns package root and cache key
+func (s *Server) lookup(path string) (string, string) {
+	s.Lock()
+	defer s.Unlock()
+
+	root, key := "", ""
+	for pkg, k := range s.repos {
+		if (path == pkg || strings.HasPrefix(path, pkg+"/")) && len(pkg) > len(root) {
+			root, key = pkg, k
+		}
+	}
+
+	return root, key
+}
+
+// refresh rescan cache every Refresh, repos fetched after start are served
+func (s *Server) refresh() {
+	for range time.Tick(s.Refresh) {
+		if err := s.Scan(); err != nil {
+			s.Warn("scan %s fail:%+v", s.CacheDir, err)
+		}
+	}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	s.Debug("%s %s", r.Method, r.URL)
+
+	switch {
+	case r.URL.Query().Get("go-get") == "1":
+		s.serveMeta(w, r, path)
+	case s.Proxy && (strings.Contains(path, "/@v/") || strings.HasSuffix(path, "/@latest")):
+		s.serveProxy(w, r, path)
+	default:
+		s.serveGit(w, r, path)
+	}
+}
+
+// serveMeta answer go get meta request
+func (s *Server) serveMeta(w http.ResponseWriter, r *http.Request, path string) {
+	root, _ := s.lookup(path)
+	if root == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<html><head><meta name="go-import" content="%s git %s://%s/%s"></head><body>go get %s</body></html>`,
+		root, scheme, r.Host, root, path)
+}
+
+// serveGit serve repo by git http-backend
+func (s *Server) serveGit(w http.ResponseWriter, r *http.Request, path string) {
+	// github.com/user/repo.git/info/refs -> github.com/user/repo/info/refs
+	if i := strings.Index(path, ".git/"); i != -1 {
+		path = path[:i] + path[i+len(".git"):]
+	}
+
+	root, key := s.lookup(path)
+	if root == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	git, err := exec.LookPath("git")
+	if err != nil {
+		http.Error(w, "git not found", http.StatusInternalServerError)
+		return
+	}
+
+	// rewrite path to cache key, relative to GIT_PROJECT_ROOT
+	u := *r.URL
+	u.Path = "/" + key + path[len(root):]
+	req := *r
+	req.URL = &u
+
+	h := &cgi.Handler{
+		Path: git,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + s.CacheDir, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	h.ServeHTTP(w, &req)
+}
+
+// serveProxy serve GOPROXY protocol: list, info, mod, zip and @latest
+func (s *Server) serveProxy(w http.ResponseWriter, r *http.Request, path string) {
+	var module, file string
+	if strings.HasSuffix(path, "/@latest") {
+		module, file = strings.TrimSuffix(path, "/@latest"), "@latest"
+	} else {
+		i := strings.Index(path, "/@v/")
+		module, file = path[:i], path[i+len("/@v/"):]
+	}
+
+	module, err := unescapeModule(module)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	root, key := s.lookup(module)
+	if root != module {
+		// sub directory module is not supported
+		http.NotFound(w, r)
+		return
+	}
+
+	dir := filepath.Join(s.CacheDir, key)
+	versions := gitSemverTags(dir)
+	if file == "list" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, v := range versions {
+			fmt.Fprintln(w, v)
+		}
+		return
+	}
+
+	version := ""
+	if file == "@latest" {
+		if len(versions) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		version, file = versions[len(versions)-1], ".info"
+	} else {
+		ext := filepath.Ext(file)
+		version, file = strings.TrimSuffix(file, ext), ext
+	}
+
+	if !hasString(versions, version) {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch file {
+	case ".info":
+		out, err := exec.Command("git", "-C", dir, "log", "-1", "--format=%cI", "refs/tags/"+version).Output()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"Version": version, "Time": strings.TrimSpace(string(out))})
+	case ".mod":
+		out, err := exec.Command("git", "-C", dir, "show", "refs/tags/"+version+":go.mod").Output()
+		if err != nil {
+			// synthesize go.mod for GOPATH era package
+			out = []byte(fmt.Sprintf("module %s\n", module))
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(out)
+	case ".zip":
+		cmd := exec.Command("git", "-C", dir, "archive", "--format=zip", "--prefix="+module+"@"+version+"/", "refs/tags/"+version)
+		cmd.Stdout = w
+		w.Header().Set("Content-Type", "application/zip")
+		if err := cmd.Run(); err != nil {
+			s.Error("archive %s@%s fail:%+v", module, version, err)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// gitSemverTags returns semver tags with v prefix, sorted
+func gitSemverTags(dir string) []string {
+	out, err := exec.Command("git", "-C", dir, "tag", "-l").Output()
+	if err != nil {
+		return nil
+	}
+
+	semvers := []*semver.Version{}
+	for _, tag := range strings.Fields(string(out)) {
+		if !strings.HasPrefix(tag, "v") {
+			continue
+		}
+
+		if v, err := semver.NewVersion(tag); err == nil {
+			semvers = append(semvers, v)
+		}
+	}
+
+	sort.Sort(semver.Collection(semvers))
+	result := []string{}
+	for _, v := range semvers {
+		result = append(result, v.Original())
+	}
+
+	return result
+}
+
+// unescapeModule decode module path escaped by GOPROXY protocol, !x -> X
+func unescapeModule(path string) (string, error) {
+	path, err := url.PathUnescape(path)
+	if err != nil {
+		return "", err
+	}
+
+	buf := []byte{}
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == '!' {
+			if i+1 >= len(path) || path[i+1] < 'a' || path[i+1] > 'z' {
+				return "", fmt.Errorf("invalid escaped module path:%+v", path)
+			}
+			i++
+			c = path[i] - 'a' + 'A'
+		}
+		buf = append(buf, c)
+	}
+
+	return string(buf), nil
+}
+
+// PackagePath returns package path of repo url
+// git@github.com:user/repo.git -> github.com/user/repo
+// https://github.com/user/repo.git -> github.com/user/repo
+func PackagePath(repo string) string {
+	host, path := "", ""
+	if m := scpSyntaxRe.FindStringSubmatch(repo); m != nil {
+		host, path = m[2], m[3]
+	} else if u, err := url.Parse(repo); err == nil {
+		host, path = u.Host, u.Path
+	} else {
+		return ""
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" {
+		return path
+	}
+
+	return host + "/" + path
+}
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ListenAndServe scan cache and start http server
+func (s *Server) ListenAndServe(addr string) error {
+	if !Exists(s.CacheDir) {
+		return os.ErrNotExist
+	}
+
+	if err := s.Scan(); err != nil {
+		return err
+	}
+
+	for _, pkg := range s.Packages() {
+		s.Info("serve %s", pkg)
+	}
+
+	if s.Refresh > 0 {
+		go s.refresh()
+	}
+
+	s.Info("listen on %s", addr)
+	return http.ListenAndServe(addr, s)
+}
@@ -0,0 +1,202 @@
+package gpm
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// cacheRepo create a git repo in cache dir with files and tags, origin is remote
+func cacheRepo(t *testing.T, cache string, key string, remote string, files map[string]string, tags ...string) {
+	dir := filepath.Join(cache, key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, body := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmds := [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"commit", "-q", "-m", "init"},
+		{"remote", "add", "origin", remote},
+	}
+	for _, tag := range tags {
+		cmds = append(cmds, []string{"tag", tag})
+	}
+
+	for _, args := range cmds {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=gpm", "GIT_AUTHOR_EMAIL=gpm@example.com", "GIT_COMMITTER_NAME=gpm", "GIT_COMMITTER_EMAIL=gpm@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s fail:%s", args[0], out)
+		}
+	}
+}
+
+// testServer returns server of a cache with two repos, skipped without git
+func testServer(t *testing.T) (*Server, string) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+
+	cache := tempDir(t)
+	cacheRepo(t, cache, "repo-key", "https://example.com/user/repo.git", map[string]string{"repo.go": "package repo\n", "go.mod": "module example.com/user/repo\n"}, "v1.0.0", "v1.2.0", "v1.10.0", "release")
+	cacheRepo(t, cache, "old-key", "git@example.com:user/Old.git", map[string]string{"old.go": "package old\n"}, "v0.1.0")
+
+	// not repos, skipped
+	os.MkdirAll(filepath.Join(cache, "archives"), 0755)
+	os.MkdirAll(filepath.Join(cache, ".bundle-1"), 0755)
+
+	s := NewServer(cache, NewLogger())
+	s.Proxy = true
+	if err := s.Scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	return s, cache
+}
+
+func get(s *Server, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", url, nil))
+	return w
+}
+
+func TestServerScan(t *testing.T) {
+	s, cache := testServer(t)
+	defer os.RemoveAll(cache)
+
+	if pkgs := strings.Join(s.Packages(), ","); pkgs != "example.com/user/Old,example.com/user/repo" {
+		t.Fatalf("packages: %s", pkgs)
+	}
+
+	cases := map[string]string{
+		"example.com/user/repo":         "repo-key",
+		"example.com/user/repo/sub/pkg": "repo-key",
+		"example.com/user/repox":        "",
+		"example.com/user":              "",
+	}
+	for path, key := range cases {
+		if _, k := s.lookup(path); k != key {
+			t.Errorf("lookup %s = %q, expected %q", path, k, key)
+		}
+	}
+}
+
+func TestServerMissUsesIndex(t *testing.T) {
+	s, cache := testServer(t)
+	defer os.RemoveAll(cache)
+
+	// fetched after scan, not served until the index is refreshed
+	cacheRepo(t, cache, "new-key", "https://example.com/user/new.git", map[string]string{"new.go": "package new\n"})
+	if w := get(s, "/example.com/user/new?go-get=1"); w.Code != http.StatusNotFound {
+		t.Fatalf("miss rescans cache: %d", w.Code)
+	}
+
+	if err := s.Scan(); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := get(s, "/example.com/user/new?go-get=1"); w.Code != http.StatusOK {
+		t.Fatalf("not served after refresh: %d", w.Code)
+	}
+}
+
+func TestServeMeta(t *testing.T) {
+	s, cache := testServer(t)
+	defer os.RemoveAll(cache)
+
+	w := get(s, "http://mirror:8080/example.com/user/repo/sub?go-get=1")
+	expected := `<meta name="go-import" content="example.com/user/repo git http://mirror:8080/example.com/user/repo">`
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), expected) {
+		t.Fatalf("meta %d: %s", w.Code, w.Body)
+	}
+
+	if w := get(s, "/example.com/unknown?go-get=1"); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown package: %d", w.Code)
+	}
+}
+
+func TestServeProxy(t *testing.T) {
+	s, cache := testServer(t)
+	defer os.RemoveAll(cache)
+
+	if w := get(s, "/example.com/user/repo/@v/list"); w.Body.String() != "v1.0.0\nv1.2.0\nv1.10.0\n" {
+		t.Errorf("list: %q", w.Body)
+	}
+
+	info := map[string]string{}
+	w := get(s, "/example.com/user/repo/@latest")
+	if err := json.Unmarshal(w.Body.Bytes(), &info); err != nil || info["Version"] != "v1.10.0" || info["Time"] == "" {
+		t.Errorf("latest: %s", w.Body)
+	}
+
+	if w := get(s, "/example.com/user/repo/@v/v1.2.0.mod"); w.Body.String() != "module example.com/user/repo\n" {
+		t.Errorf("mod: %q", w.Body)
+	}
+
+	// escaped upper case, go.mod is synthesized for GOPATH era package
+	if w := get(s, "/example.com/user/!old/@v/v0.1.0.mod"); w.Body.String() != "module example.com/user/Old\n" {
+		t.Errorf("synthesized mod: %q", w.Body)
+	}
+
+	w = get(s, "/example.com/user/repo/@v/v1.0.0.zip")
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("zip: %+v", err)
+	}
+
+	names := []string{}
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if !hasString(names, "example.com/user/repo@v1.0.0/repo.go") {
+		t.Errorf("zip files: %v", names)
+	}
+
+	for _, url := range []string{"/example.com/user/repo/@v/v9.0.0.info", "/example.com/user/repo/@v/release.info", "/example.com/user/repo/sub/@v/list"} {
+		if w := get(s, url); w.Code != http.StatusNotFound {
+			t.Errorf("%s: %d", url, w.Code)
+		}
+	}
+}
+
+func TestPackagePath(t *testing.T) {
+	cases := map[string]string{
+		"git@github.com:user/repo.git":     "github.com/user/repo",
+		"https://github.com/user/repo.git": "github.com/user/repo",
+		"https://github.com/user/repo/":    "github.com/user/repo",
+		"ssh://git@host.com/a/b":           "host.com/a/b",
+	}
+
+	for repo, expected := range cases {
+		if actual := PackagePath(repo); actual != expected {
+			t.Errorf("PackagePath(%s) = %s, expected %s", repo, actual, expected)
+		}
+	}
+}
+
+func TestUnescapeModule(t *testing.T) {
+	if m, err := unescapeModule("github.com/!azure/go-!a!p!i"); err != nil || m != "github.com/Azure/go-API" {
+		t.Errorf("unescape: %s, %+v", m, err)
+	}
+
+	for _, bad := range []string{"github.com/!", "github.com/!A"} {
+		if _, err := unescapeModule(bad); err == nil {
+			t.Errorf("unescape %s should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,507 @@
+package gpm
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// SettingsName is the settings file in ~/.gpm and /etc/gpm
+const SettingsName = "config.yaml"
+
+// SystemSettings is the settings file shared by all users
+var SystemSettings = "/etc/gpm/config.yaml"
+
+// OriginDefault is the origin of default values
+const OriginDefault = "default"
+
+// Settings are per user or per machine defaults, merged in order:
+// default, system, user, project gpm.yaml, environment variables, flags
+type Settings struct {
+	Cache            string            `yaml:"cache,omitempty"`             // cache dir, default is ~/.gpm
+	TrustedKeys      string            `yaml:"trusted-keys,omitempty"`      // dir of gpg(.asc/.gpg) and ssh(.pub) public keys
+	Mirrors          map[string]string `yaml:"mirrors,omitempty"`           // package prefix -> url prefix
+	CredentialHelper string            `yaml:"credential-helper,omitempty"` // git credential helper
+	Proxy            string            `yaml:"proxy,omitempty"`             // http proxy for git and downloads
+	Jobs             int               `yaml:"jobs,omitempty"`              // default parallel jobs
+	Color            string            `yaml:"color,omitempty"`             // auto, always or never
+	GoRoots          []string          `yaml:"go-roots,omitempty"`          // installed go sdks, globs like ~/sdk/go*
+	origins          map[string]string // key -> file, env or flag where the value came from
+	source           []byte            // content loaded, edited in place by SaveFile
+}
+
+// settingKeys are keys of settings, mirrors is set by mirrors.<package>
+var settingKeys = []string{"cache", "color", "credential-helper", "go-roots", "jobs", "mirrors", "proxy", "trusted-keys"}
+
+// machineKeys are accepted from system and user settings only, a project or its
+// bases must not choose the keys to trust, the commands git runs or the go binary
+var machineKeys = []string{"credential-helper", "go-roots", "trusted-keys"}
+
+// IsMachineKey returns true if key is accepted from system and user settings only
+func IsMachineKey(key string) bool {
+	return hasString(machineKeys, key)
+}
+
+// settingEnvs are environment variables of settings, GPM_MIRRORS is prefix=url,prefix=url,
+// GPM_GO_ROOTS is dir,dir
+var settingEnvs = map[string]string{
+	"cache":             "GPM_CACHE",
+	"color":             "GPM_COLOR",
+	"credential-helper": "GPM_CREDENTIAL_HELPER",
+	"go-roots":          "GPM_GO_ROOTS",
+	"jobs":              "GPM_JOBS",
+	"mirrors":           "GPM_MIRRORS",
+	"proxy":             "GPM_PROXY",
+	"trusted-keys":      "GPM_TRUSTED_KEYS",
+}
+
+// settingFlags are global flags of settings
+var settingFlags = map[string]string{
+	"cache": "cache-dir",
+	"color": "color",
+	"jobs":  "jobs",
+}
+
+// UserDir returns ~/.gpm
+func UserDir() string {
+	home, err := Home()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".gpm")
+}
+
+// UserSettings returns ~/.gpm/config.yaml
+func UserSettings() string {
+	return filepath.Join(UserDir(), SettingsName)
+}
+
+// DefaultSettings returns settings used when nothing is configured
+func DefaultSettings() *Settings {
+	s := &Settings{
+		Cache:       UserDir(),
+		TrustedKeys: filepath.Join(UserDir(), "trusted-keys"),
+		Jobs:        runtime.NumCPU(),
+		Color:       "auto",
+	}
+
+	s.origins = make(map[string]string)
+	for key := range s.Values() {
+		s.origins[key] = OriginDefault
+	}
+
+	return s
+}
+
+// LoadSettingsFile load settings file, returns nil if not exists
+func LoadSettingsFile(file string) (*Settings, error) {
+	if !Exists(file) {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Settings{}
+	if err := yaml.UnmarshalStrict(data, s); err != nil {
+		return nil, fmt.Errorf("load %s fail:%+v", file, err)
+	}
+
+	for key, value := range s.Values() {
+		if err := s.Set(key, value); err != nil {
+			return nil, fmt.Errorf("load %s fail:%+v", file, err)
+		}
+	}
+
+	s.source = data
+	return s, nil
+}
+
+// SaveFile save settings file, comments are kept if loaded from file
+func (s *Settings) SaveFile(file string) error {
+	var data []byte
+	var err error
+	if s.source != nil {
+		data, err = EditYAML(s.source, s)
+	} else {
+		data, err = yaml.Marshal(s)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, data, 0666)
+}
+
+// Values returns flatten values which are set, mirrors are mirrors.<package>
+func (s *Settings) Values() map[string]string {
+	result := make(map[string]string)
+	for _, key := range settingKeys {
+		if value, ok := s.Get(key); ok {
+			result[key] = value
+		}
+	}
+
+	for prefix, url := range s.Mirrors {
+		result["mirrors."+prefix] = url
+	}
+
+	return result
+}
+
+// Keys returns sorted keys which are set
+func (s *Settings) Keys() []string {
+	keys := []string{}
+	for key := range s.Values() {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Get returns value of key, false if not set
+func (s *Settings) Get(key string) (string, bool) {
+	value := ""
+	switch {
+	case key == "cache":
+		value = s.Cache
+	case key == "trusted-keys":
+		value = s.TrustedKeys
+	case key == "credential-helper":
+		value = s.CredentialHelper
+	case key == "proxy":
+		value = s.Proxy
+	case key == "color":
+		value = s.Color
+	case key == "jobs" && s.Jobs != 0:
+		value = strconv.Itoa(s.Jobs)
+	case key == "go-roots":
+		value = strings.Join(s.GoRoots, ",")
+	case strings.HasPrefix(key, "mirrors."):
+		value = s.Mirrors[strings.TrimPrefix(key, "mirrors.")]
+	}
+
+	return value, value != ""
+}
+
+// Set validate and set value of key
+func (s *Settings) Set(key string, value string) error {
+	switch {
+	case key == "cache":
+		s.Cache = value
+	case key == "trusted-keys":
+		s.TrustedKeys = value
+	case key == "credential-helper":
+		s.CredentialHelper = value
+	case key == "proxy":
+		s.Proxy = value
+	case key == "color":
+		if value != "auto" && value != "always" && value != "never" {
+			return fmt.Errorf("invalid color %s, should be auto, always or never", value)
+		}
+		s.Color = value
+	case key == "jobs":
+		jobs, err := strconv.Atoi(value)
+		if err != nil || jobs <= 0 {
+			return fmt.Errorf("invalid jobs %s, should be a positive number", value)
+		}
+		s.Jobs = jobs
+	case key == "go-roots":
+		s.GoRoots = nil
+		for _, root := range strings.Split(value, ",") {
+			if root = strings.TrimSpace(root); root != "" {
+				s.GoRoots = append(s.GoRoots, root)
+			}
+		}
+	case key == "mirrors":
+		mirrors, err := parseMirrors(value)
+		if err != nil {
+			return err
+		}
+
+		for prefix, url := range mirrors {
+			s.Set("mirrors."+prefix, url)
+		}
+	case strings.HasPrefix(key, "mirrors.") && key != "mirrors.":
+		if s.Mirrors == nil {
+			s.Mirrors = make(map[string]string)
+		}
+		s.Mirrors[strings.TrimPrefix(key, "mirrors.")] = value
+	default:
+		return fmt.Errorf("unknown key %s", key)
+	}
+
+	return nil
+}
+
+// Unset remove value of key, returns false if not set
+func (s *Settings) Unset(key string) bool {
+	if _, ok := s.Get(key); !ok {
+		return false
+	}
+
+	switch {
+	case key == "cache":
+		s.Cache = ""
+	case key == "trusted-keys":
+		s.TrustedKeys = ""
+	case key == "credential-helper":
+		s.CredentialHelper = ""
+	case key == "proxy":
+		s.Proxy = ""
+	case key == "color":
+		s.Color = ""
+	case key == "jobs":
+		s.Jobs = 0
+	case key == "go-roots":
+		s.GoRoots = nil
+	default:
+		delete(s.Mirrors, strings.TrimPrefix(key, "mirrors."))
+	}
+
+	return true
+}
+
+// MachineKeys returns keys which are set but accepted from system and user settings only
+func (s *Settings) MachineKeys() []string {
+	keys := []string{}
+	for _, key := range s.Keys() {
+		if IsMachineKey(key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// clone returns a copy which can be changed without changing s
+func (s *Settings) clone() *Settings {
+	c := *s
+	c.Mirrors = make(map[string]string)
+	for prefix, url := range s.Mirrors {
+		c.Mirrors[prefix] = url
+	}
+
+	c.GoRoots = append([]string(nil), s.GoRoots...)
+	c.origins = make(map[string]string)
+	for key, origin := range s.origins {
+		c.origins[key] = origin
+	}
+
+	return &c
+}
+
+// Origin returns where the value of key came from
+func (s *Settings) Origin(key string) string {
+	return s.origins[key]
+}
+
+// merge set values of other, relative paths are relative to base
+func (s *Settings) merge(other *Settings, origin string, base string) error {
+	for key, value := range other.Values() {
+		if err := s.setOrigin(key, value, origin, base); err != nil {
+			return fmt.Errorf("%s: %+v", origin, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Settings) setOrigin(key string, value string, origin string, base string) error {
+	if key == "mirrors" {
+		mirrors, err := parseMirrors(value)
+		if err != nil {
+			return err
+		}
+
+		for prefix, url := range mirrors {
+			s.setOrigin("mirrors."+prefix, url, origin, base)
+		}
+		return nil
+	}
+
+	if key == "cache" || key == "trusted-keys" {
+		value = ExpandHome(value)
+		if !filepath.IsAbs(value) {
+			value = filepath.Join(base, value)
+		}
+	}
+
+	if key == "go-roots" {
+		roots := []string{}
+		for _, root := range strings.Split(value, ",") {
+			if root = ExpandHome(strings.TrimSpace(root)); root != "" && !filepath.IsAbs(root) {
+				root = filepath.Join(base, root)
+			}
+			roots = append(roots, root)
+		}
+		value = strings.Join(roots, ",")
+	}
+
+	if err := s.Set(key, value); err != nil {
+		return err
+	}
+
+	s.origins[key] = origin
+	return nil
+}
+
+// parseMirrors parse prefix=url,prefix=url
+func parseMirrors(value string) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid mirror %s, should be package=url", item)
+		}
+
+		result[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return result, nil
+}
+
+// Mirror rewrite url by the longest mirror prefix, returns url if no mirror matches
+func (s *Settings) Mirror(url string) string {
+	name := VendorPath(url)
+	found := ""
+	for prefix := range s.Mirrors {
+		if (name == prefix || strings.HasPrefix(name, prefix+"/")) && len(prefix) > len(found) {
+			found = prefix
+		}
+	}
+
+	if found == "" {
+		return url
+	}
+
+	return strings.TrimSuffix(s.Mirrors[found], "/") + strings.TrimPrefix(name, found)
+}
+
+// LoadSettings merge settings in order: default, system, user, environment variables,
+// global flags. called once after -C, settings of gpm.yaml are applied by MustLoad
+func (ctx *Ctx) LoadSettings() {
+	s := DefaultSettings()
+	for _, file := range []string{SystemSettings, UserSettings()} {
+		layer, err := LoadSettingsFile(file)
+		if err != nil {
+			ctx.Die("%+v", err)
+		}
+
+		if layer != nil {
+			if err := s.merge(layer, file, filepath.Dir(file)); err != nil {
+				ctx.Die("%+v", err)
+			}
+		}
+	}
+
+	for _, key := range settingKeys {
+		env := settingEnvs[key]
+		if value := os.Getenv(env); value != "" {
+			if err := s.setOrigin(key, value, "env "+env, ctx.StartDir); err != nil {
+				ctx.Die("%s:%+v", env, err)
+			}
+		}
+	}
+
+	if ctx.Context != nil {
+		for _, key := range settingKeys {
+			flag, ok := settingFlags[key]
+			if ok && ctx.GlobalIsSet(flag) {
+				if err := s.setOrigin(key, ctx.GlobalString(flag), "flag --"+flag, ctx.StartDir); err != nil {
+					ctx.Die("--%s:%+v", flag, err)
+				}
+			}
+		}
+	}
+
+	ctx.loaded = s
+	ctx.Settings = s.clone()
+	ctx.applySettings()
+}
+
+// applyProjectSettings merge settings of gpm.yaml on the loaded ones, environment
+// variables and flags keep precedence, machine keys are ignored
+func (ctx *Ctx) applyProjectSettings() {
+	if ctx.loaded == nil {
+		ctx.LoadSettings()
+	}
+
+	s := ctx.loaded.clone()
+	if ctx.ProjectSettings != nil {
+		origin := filepath.Join(ctx.WorkDir, ConfName)
+		for key, value := range ctx.ProjectSettings.Values() {
+			old := s.origins[key]
+			if IsMachineKey(key) || strings.HasPrefix(old, "env ") || strings.HasPrefix(old, "flag ") {
+				continue
+			}
+
+			if err := s.setOrigin(key, value, origin, ctx.WorkDir); err != nil {
+				ctx.Die("%s: %+v", origin, err)
+			}
+		}
+	}
+
+	ctx.Settings = s
+	ctx.applySettings()
+}
+
+// applySettings apply settings to context and environment of git
+func (ctx *Ctx) applySettings() {
+	s := ctx.Settings
+	ctx.CacheDir = s.Cache
+	switch s.Color {
+	case "always":
+		ctx.NoColor = false
+	case "never":
+		ctx.NoColor = true
+	default:
+		ctx.NoColor = !isTerminal(os.Stdout)
+	}
+
+	if s.Proxy != "" {
+		for _, env := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+			os.Setenv(env, s.Proxy)
+		}
+	}
+
+	if s.CredentialHelper != "" {
+		setGitConfig("credential.helper", s.CredentialHelper)
+	}
+}
+
+// setGitConfig pass config to all git commands by GIT_CONFIG_COUNT
+func setGitConfig(key string, value string) {
+	count, _ := strconv.Atoi(os.Getenv("GIT_CONFIG_COUNT"))
+	for i := 0; i < count; i++ {
+		if os.Getenv(fmt.Sprintf("GIT_CONFIG_KEY_%d", i)) == key {
+			os.Setenv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", i), value)
+			return
+		}
+	}
+
+	os.Setenv(fmt.Sprintf("GIT_CONFIG_KEY_%d", count), key)
+	os.Setenv(fmt.Sprintf("GIT_CONFIG_VALUE_%d", count), value)
+	os.Setenv("GIT_CONFIG_COUNT", strconv.Itoa(count+1))
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
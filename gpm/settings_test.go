@@ -0,0 +1,150 @@
+package gpm
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSettingsSet(t *testing.T) {
+	cases := []struct {
+		key, value, expected string
+		invalid              bool
+	}{
+		{"color", "never", "never", false},
+		{"color", "red", "", true},
+		{"jobs", "4", "4", false},
+		{"jobs", "0", "", true},
+		{"jobs", "x", "", true},
+		{"go-roots", " /a , ,/b", "/a,/b", false},
+		{"mirrors.github.com/a", "https://m/a", "https://m/a", false},
+		{"mirrors.", "https://m", "", true},
+		{"unknown", "x", "", true},
+	}
+
+	for _, tc := range cases {
+		s := &Settings{}
+		err := s.Set(tc.key, tc.value)
+		if (err != nil) != tc.invalid {
+			t.Errorf("Set(%s, %s) error %v, expected invalid %v", tc.key, tc.value, err, tc.invalid)
+			continue
+		}
+
+		if value, _ := s.Get(tc.key); !tc.invalid && value != tc.expected {
+			t.Errorf("Get(%s) = %q, expected %q", tc.key, value, tc.expected)
+		}
+	}
+
+	s := &Settings{}
+	if err := s.Set("mirrors", "github.com/a=https://m/a, golang.org/x = https://m/x"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"mirrors.github.com/a", "mirrors.golang.org/x"}
+	if keys := s.Keys(); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("keys %v, expected %v", keys, expected)
+	}
+
+	if err := s.Set("mirrors", "github.com/a"); err == nil {
+		t.Error("mirror without url is accepted")
+	}
+}
+
+func TestMirror(t *testing.T) {
+	s := &Settings{Mirrors: map[string]string{
+		"github.com":          "https://mirror/gh/",
+		"github.com/org/repo": "https://mirror/repo",
+		"golang.org/x":        "https://mirror/x",
+	}}
+
+	cases := map[string]string{
+		"https://github.com/org/repo":     "https://mirror/repo",
+		"https://github.com/org/repo/sub": "https://mirror/repo/sub",
+		"git@github.com:org/other.git":    "https://mirror/gh/org/other",
+		"https://github.com/org/repo2":    "https://mirror/gh/org/repo2",
+		"golang.org/x/net":                "https://mirror/x/net",
+		"https://golang.org/xy":           "https://golang.org/xy",
+		"https://gitlab.com/a/b":          "https://gitlab.com/a/b",
+	}
+
+	for url, expected := range cases {
+		if actual := s.Mirror(url); actual != expected {
+			t.Errorf("Mirror(%s) = %s, expected %s", url, actual, expected)
+		}
+	}
+}
+
+func TestMachineKeys(t *testing.T) {
+	s := &Settings{Cache: "/c", TrustedKeys: "/k", CredentialHelper: "store", GoRoots: []string{"/go"}, Jobs: 2}
+	expected := []string{"credential-helper", "go-roots", "trusted-keys"}
+	if keys := s.MachineKeys(); !reflect.DeepEqual(keys, expected) {
+		t.Errorf("MachineKeys() = %v, expected %v", keys, expected)
+	}
+
+	for _, key := range []string{"cache", "jobs", "mirrors.github.com", "proxy"} {
+		if IsMachineKey(key) {
+			t.Errorf("IsMachineKey(%s) = true", key)
+		}
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	// user settings are between system and env, can not be moved away
+	if user, _ := LoadSettingsFile(UserSettings()); user != nil && len(user.Keys()) > 0 {
+		t.Skipf("%s is not empty", UserSettings())
+	}
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	system := SystemSettings
+	defer func() { SystemSettings = system }()
+	SystemSettings = filepath.Join(dir, "etc", SettingsName)
+	writeTree(t, dir, map[string]string{
+		"etc/" + SettingsName: "cache: cache\njobs: 2\ncolor: never\ntrusted-keys: keys\nmirrors:\n  github.com: https://m/gh\n",
+	})
+
+	os.Setenv("GPM_JOBS", "8")
+	defer os.Unsetenv("GPM_JOBS")
+
+	ctx := &Ctx{Logger: NewLogger(), Config: &Config{}, StartDir: dir, WorkDir: filepath.Join(dir, "app")}
+	ctx.LoadSettings()
+
+	cases := []struct {
+		key, value, origin string
+	}{
+		{"cache", filepath.Join(dir, "etc", "cache"), SystemSettings},
+		{"trusted-keys", filepath.Join(dir, "etc", "keys"), SystemSettings},
+		{"jobs", "8", "env GPM_JOBS"},
+		{"color", "never", SystemSettings},
+		{"mirrors.github.com", "https://m/gh", SystemSettings},
+	}
+
+	check := func(s *Settings) {
+		for _, tc := range cases {
+			if value, _ := s.Get(tc.key); value != tc.value || s.Origin(tc.key) != tc.origin {
+				t.Errorf("%s = %s from %s, expected %s from %s", tc.key, value, s.Origin(tc.key), tc.value, tc.origin)
+			}
+		}
+	}
+
+	check(ctx.Settings)
+	if ctx.CacheDir != filepath.Join(dir, "etc", "cache") {
+		t.Errorf("cache dir %s", ctx.CacheDir)
+	}
+
+	// gpm.yaml overrides files but not env, machine keys are ignored
+	ctx.ProjectSettings = &Settings{Cache: ".cache", Jobs: 1, TrustedKeys: "/tmp/evil", Mirrors: map[string]string{"github.com": "https://p/gh"}}
+	ctx.applyProjectSettings()
+
+	project := filepath.Join(ctx.WorkDir, ConfName)
+	cases[0] = struct{ key, value, origin string }{"cache", filepath.Join(dir, "app", ".cache"), project}
+	cases[4] = struct{ key, value, origin string }{"mirrors.github.com", "https://p/gh", project}
+	check(ctx.Settings)
+
+	// loaded layers are not changed by project
+	if value, _ := ctx.loaded.Get("mirrors.github.com"); value != "https://m/gh" {
+		t.Errorf("loaded mirror changed to %s", value)
+	}
+}
@@ -0,0 +1,121 @@
+package gpm
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/vcs"
+)
+
+var (
+	gpgGoodSig  = regexp.MustCompile(`\[GNUPG:\] GOODSIG \S+ (.+)`)
+	gpgValidSig = regexp.MustCompile(`\[GNUPG:\] VALIDSIG (\S+)`)
+	sshGoodSig  = regexp.MustCompile(`Good "git" signature for (.+) with \S+ key (\S+)`)
+)
+
+// VerifyTag verify gpg or ssh signature of tag in repo dir against public keys in keys dir,
+// returns the signer. gpg keys are *.asc or *.gpg, ssh keys are *.pub
+func VerifyTag(dir string, tag string, keys string) (string, error) {
+	infos, err := ioutil.ReadDir(keys)
+	if err != nil {
+		return "", fmt.Errorf("no trusted keys:%+v", err)
+	}
+
+	tmp, err := TempDir("verify")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	// use a temporary keyring, only trusted keys are known
+	gnupg := filepath.Join(tmp, "gnupg")
+	if err := os.MkdirAll(gnupg, 0700); err != nil {
+		return "", err
+	}
+
+	signers := &bytes.Buffer{}
+	for _, fi := range infos {
+		file := filepath.Join(keys, fi.Name())
+		switch strings.ToLower(filepath.Ext(fi.Name())) {
+		case ".asc", ".gpg":
+			if out, err := exec.Command("gpg", "--batch", "--homedir", gnupg, "--import", file).CombinedOutput(); err != nil {
+				return "", fmt.Errorf("import key %s fail:%s", file, bytes.TrimSpace(out))
+			}
+		case ".pub":
+			data, err := ioutil.ReadFile(file)
+			if err != nil {
+				return "", err
+			}
+
+			// allowed signers: principal keytype key, principal is the key comment or file name
+			for _, line := range strings.Split(string(data), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+					continue
+				}
+
+				principal := strings.TrimSuffix(fi.Name(), filepath.Ext(fi.Name()))
+				if len(fields) > 2 {
+					principal = fields[2]
+				}
+
+				fmt.Fprintf(signers, "%s namespaces=\"git\" %s %s\n", principal, fields[0], fields[1])
+			}
+		}
+	}
+
+	allowed := filepath.Join(tmp, "allowed_signers")
+	if err := ioutil.WriteFile(allowed, signers.Bytes(), 0600); err != nil {
+		return "", err
+	}
+
+	cmd := exec.Command("git", "-c", "gpg.ssh.allowedSignersFile="+allowed, "verify-tag", "--raw", tag)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GNUPGHOME="+gnupg)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("verify tag %s fail:%s", tag, bytes.TrimSpace(out))
+	}
+
+	if m := gpgGoodSig.FindSubmatch(out); m != nil {
+		signer := string(bytes.TrimSpace(m[1]))
+		if v := gpgValidSig.FindSubmatch(out); v != nil {
+			signer += " " + string(v[1])
+		}
+		return signer, nil
+	}
+
+	// ssh signature of unknown key is reported good without principal
+	if m := sshGoodSig.FindSubmatch(out); m != nil {
+		return string(m[1]) + " " + string(m[2]), nil
+	}
+
+	return "", fmt.Errorf("verify tag %s fail: signer is not trusted", tag)
+}
+
+// verifySignature verify the resolved tag of repo if required by dependency
+func (ctx *Ctx) verifySignature(dep *Dependency, repo vcs.Repo, lock *Lock) error {
+	if !dep.VerifySignature {
+		return nil
+	}
+
+	tag := repoVersion(repo)
+	if tag == "" {
+		return fmt.Errorf("verify signature of %s fail: no tag at current reversion", dep.Name)
+	}
+
+	signer, err := VerifyTag(repo.LocalPath(), tag, ctx.Settings.TrustedKeys)
+	if err != nil {
+		return fmt.Errorf("%s: %+v", dep.Name, err)
+	}
+
+	ctx.Info("--> Verified %s@%s signed by %s", dep.Name, tag, signer)
+	lock.Signer = signer
+	return nil
+}
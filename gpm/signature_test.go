@@ -0,0 +1,65 @@
+package gpm
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyTag(t *testing.T) {
+	if _, err := exec.LookPath("ssh-keygen"); err != nil {
+		t.Skip("ssh-keygen not found")
+	}
+
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	run := func(dir string, name string, args ...string) {
+		cmd := exec.Command(name, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "HOME="+root)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Skipf("%s %v: %s", name, args, out)
+		}
+	}
+
+	// keys of a trusted and an unknown signer
+	for _, name := range []string{"trusted", "unknown"} {
+		run(root, "ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", name+"@example.com", "-f", filepath.Join(root, name))
+	}
+
+	keys := filepath.Join(root, "keys")
+	writeTree(t, keys, map[string]string{"trusted.pub": readString(t, filepath.Join(root, "trusted.pub"))})
+
+	repo := filepath.Join(root, "repo")
+	writeTree(t, repo, map[string]string{"lib.go": "package lib\n"})
+	run(repo, "git", "init", "-q")
+	run(repo, "git", "-c", "user.name=a", "-c", "user.email=a@example.com", "add", ".")
+	run(repo, "git", "-c", "user.name=a", "-c", "user.email=a@example.com", "commit", "-q", "-m", "init")
+	run(repo, "git", "tag", "v1.0.0")
+	for _, name := range []string{"trusted", "unknown"} {
+		run(repo, "git", "-c", "user.name=a", "-c", "user.email=a@example.com", "-c", "gpg.format=ssh", "-c", "user.signingkey="+filepath.Join(root, name),
+			"tag", "-s", "-m", name, "v1.0.0-"+name)
+	}
+
+	cases := []struct {
+		tag, keys, signer, err string
+	}{
+		{"v1.0.0-trusted", keys, "trusted@example.com", ""},
+		{"v1.0.0-unknown", keys, "", "fail"},
+		{"v1.0.0", keys, "", "fail"},
+		{"v1.0.0-trusted", filepath.Join(root, "none"), "", "no trusted keys"},
+	}
+
+	for _, tc := range cases {
+		signer, err := VerifyTag(repo, tc.tag, tc.keys)
+		switch {
+		case tc.err == "" && (err != nil || !strings.HasPrefix(signer, tc.signer+" ")):
+			t.Errorf("%s: signer %q, %+v", tc.tag, signer, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Errorf("%s: expected %q, got %q %+v", tc.tag, tc.err, signer, err)
+		}
+	}
+}
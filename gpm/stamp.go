@@ -0,0 +1,215 @@
+package gpm
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Stamp inject version info into binaries by -ldflags -X, or generate a go file.
+// values are variables, eg: main.Version, or github.com/corp/svc/version.Commit
+type Stamp struct {
+	Version string `yaml:"version,omitempty"` // version of gpm.yaml
+	Commit  string `yaml:"commit,omitempty"`  // git commit of project
+	Dirty   string `yaml:"dirty,omitempty"`   // "true" if tracked files are modified
+	Time    string `yaml:"time,omitempty"`    // build time in RFC3339, SOURCE_DATE_EPOCH if set
+	Lock    string `yaml:"lock,omitempty"`    // sha256 of gpm.lock
+	File    string `yaml:"file,omitempty"`    // generate constants in this file instead, eg: version/version.go
+}
+
+// stampVar is a variable and its value, key is the field of Stamp
+type stampVar struct {
+	Key    string
+	Symbol string
+	Value  string
+}
+
+// stampKeys are keys of Stamp fields in order
+var stampKeys = []string{"version", "commit", "dirty", "time", "lock"}
+
+// defaultStampNames are constants generated if no variable is given
+var defaultStampNames = []string{"Version", "Commit", "Dirty", "BuildTime", "LockHash"}
+
+// stampVars returns variables in order of Stamp fields, variables not given are omitted
+func (ctx *Ctx) stampVars() []*stampVar {
+	s := ctx.Stamp
+	commit, dirty := ctx.gitStatus()
+	lock := ""
+	if Exists(LockName) {
+		lock, _ = LockHash()
+	}
+
+	symbols := []string{s.Version, s.Commit, s.Dirty, s.Time, s.Lock}
+	values := []string{ctx.Config.Version, commit, dirty, BuildTime().Format(time.RFC3339), lock}
+
+	given := false
+	for _, symbol := range symbols {
+		given = given || symbol != ""
+	}
+
+	// generated file has all by default
+	if !given && s.File != "" {
+		symbols = defaultStampNames
+	}
+
+	vars := []*stampVar{}
+	for i, symbol := range symbols {
+		if symbol != "" {
+			vars = append(vars, &stampVar{Key: stampKeys[i], Symbol: symbol, Value: values[i]})
+		}
+	}
+
+	return vars
+}
+
+// gitStatus returns commit and dirty flag of project, empty if not a git repo
+func (ctx *Ctx) gitStatus() (string, string) {
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", ""
+	}
+
+	// the generated file is always changed
+	args := []string{"status", "--porcelain", "--untracked-files=no"}
+	if ctx.Stamp.File != "" {
+		args = append(args, "--", ".", ":!"+filepath.ToSlash(ctx.Stamp.File))
+	}
+
+	status, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return strings.TrimSpace(string(out)), ""
+	}
+
+	return strings.TrimSpace(string(out)), strconv.FormatBool(len(bytes.TrimSpace(status)) > 0)
+}
+
+// stamper inject version info into targets which are built, cached ones are not stamped
+type stamper struct {
+	vars  []*stampVar
+	file  string // generated file, written once before the first build
+	flags string // -X flags if no file
+	once  sync.Once
+	err   error
+}
+
+// newStamper returns nil if stamp is not configured
+func (ctx *Ctx) newStamper() *stamper {
+	if ctx.Stamp == nil {
+		return nil
+	}
+
+	s := &stamper{vars: ctx.stampVars(), file: ctx.Stamp.File}
+	flags := []string{}
+	for _, v := range s.vars {
+		value := v.Symbol + "=" + v.Value
+		if strings.ContainsAny(value, " \t'\"") {
+			value = strconv.Quote(value)
+		}
+		flags = append(flags, "-X", value)
+	}
+
+	if s.file == "" {
+		s.flags = strings.Join(flags, " ")
+	}
+
+	return s
+}
+
+// settings returns values which are inputs of build cache, build time is not
+func (s *stamper) settings() map[string]string {
+	result := make(map[string]string)
+	if s == nil {
+		return result
+	}
+
+	for _, v := range s.vars {
+		if v.Key != "time" {
+			result["stamp."+v.Symbol] = v.Value
+		}
+	}
+
+	return result
+}
+
+// target returns target with version info injected, the go file is generated if stamp has file
+func (s *stamper) target(t *Target) (*Target, error) {
+	if s == nil {
+		return t, nil
+	}
+
+	if s.file != "" {
+		s.once.Do(func() { s.err = writeStampFile(s.file, s.vars) })
+		return t, s.err
+	}
+
+	copied := *t
+	copied.Ldflags = strings.TrimSpace(t.Ldflags + " " + s.flags)
+	return &copied, nil
+}
+
+// writeStampFile generate constants, package is name of dir, main in project root
+func writeStampFile(file string, vars []*stampVar) error {
+	pkg := stampPackage(file)
+
+	// aligned like gofmt
+	width := 0
+	for _, v := range vars {
+		if n := len(stampName(v.Symbol)); n > width {
+			width = n
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by gpm build. DO NOT EDIT.\n\npackage %s\n\nconst (\n", pkg)
+	for _, v := range vars {
+		fmt.Fprintf(buf, "\t%-*s = %s\n", width, stampName(v.Symbol), strconv.Quote(v.Value))
+	}
+	buf.WriteString(")\n")
+
+	// unchanged file is not written, keep go build cache
+	if old, err := ioutil.ReadFile(file); err == nil && bytes.Equal(old, buf.Bytes()) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, buf.Bytes(), 0644)
+}
+
+// stampPackage returns package of the other .go files in dir of stamp file,
+// name of dir if there is none, main for project root
+func stampPackage(file string) string {
+	dir := filepath.Dir(file)
+	files, _ := filepath.Glob(filepath.Join(dir, "*.go"))
+	for _, f := range files {
+		if filepath.Base(f) == filepath.Base(file) || strings.HasSuffix(f, "_test.go") {
+			continue
+		}
+
+		if parsed, err := parser.ParseFile(token.NewFileSet(), f, nil, parser.PackageClauseOnly); err == nil {
+			return parsed.Name.Name
+		}
+	}
+
+	if dir == "." {
+		return "main"
+	}
+
+	return filepath.Base(dir)
+}
+
+// stampName returns name of variable without package
+func stampName(symbol string) string {
+	return symbol[strings.LastIndex(symbol, ".")+1:]
+}
@@ -0,0 +1,42 @@
+package gpm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStampPackage(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"internal/buildinfo/info.go":      "// Package info has build info\npackage info\n",
+		"internal/buildinfo/info_test.go": "package info_test\n",
+		"internal/buildinfo/stamp.go":     "package buildinfo\n",
+		"version/stamp.go":                "package version\n",
+	}
+	for name, body := range files {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(file), 0755)
+		if err := ioutil.WriteFile(file, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := map[string]string{
+		"internal/buildinfo/stamp.go": "info",
+		"version/stamp.go":            "version",
+		"cmd/app/stamp.go":            "app",
+	}
+	for file, expected := range cases {
+		if pkg := stampPackage(filepath.Join(root, filepath.FromSlash(file))); pkg != expected {
+			t.Errorf("stampPackage(%s) = %s, expected %s", file, pkg, expected)
+		}
+	}
+
+	if pkg := stampPackage("stamp.go"); pkg != "gpm" {
+		t.Errorf("stampPackage in package dir = %s", pkg)
+	}
+}
@@ -0,0 +1,353 @@
+package gpm
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// TestConfig configure gpm test
+type TestConfig struct {
+	Ignore []string `yaml:"ignore,omitempty"` // dirs not tested, relative to project root
+}
+
+// TestEvent is an event of go test -json
+type TestEvent struct {
+	Action  string
+	Package string
+	Test    string
+	Elapsed float64
+	Output  string
+}
+
+// PackageResult is the result of a package, with its tests
+type PackageResult struct {
+	Package string
+	Action  string  // pass, fail or skip
+	Elapsed float64 // seconds
+	Tests   []*TestResult
+}
+
+// TestResult is the result of a test
+type TestResult struct {
+	Name    string
+	Action  string
+	Elapsed float64
+	Output  string
+}
+
+// ProjectPackages returns ./<dir> of packages in project, vendor, dist,
+// hidden dirs, dirs ignored by go and ignore are skipped
+func ProjectPackages(root string, ignore []string) ([]string, error) {
+	skip := make(map[string]bool)
+	for _, dir := range ignore {
+		skip[path.Clean(filepath.ToSlash(dir))] = true
+	}
+
+	packages := []string{}
+	seen := make(map[string]bool)
+	err := filepath.Walk(root, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		name := fi.Name()
+		if fi.IsDir() {
+			if rel != "." && (name == "vendor" || name == DistDir || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || skip[rel]) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if strings.HasSuffix(name, ".go") && !strings.HasPrefix(name, ".") && !strings.HasPrefix(name, "_") {
+			pkg := "./" + path.Dir(rel)
+			if pkg == "./." {
+				pkg = "."
+			}
+
+			if !seen[pkg] {
+				seen[pkg] = true
+				packages = append(packages, pkg)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Strings(packages)
+	return packages, err
+}
+
+// ReadTestEvents read events of go test -json, output is copied to w like go test,
+// output of passed tests is omitted unless verbose
+func ReadTestEvents(r io.Reader, w io.Writer, verbose bool) ([]*PackageResult, error) {
+	results := make(map[string]*PackageResult)
+	tests := make(map[string]*TestResult)
+	order := []string{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		e := &TestEvent{}
+		if err := json.Unmarshal(line, e); err != nil || e.Package == "" {
+			// not an event, eg: build errors
+			fmt.Fprintf(w, "%s\n", line)
+			continue
+		}
+
+		pkg, ok := results[e.Package]
+		if !ok {
+			pkg = &PackageResult{Package: e.Package}
+			results[e.Package] = pkg
+			order = append(order, e.Package)
+		}
+
+		var test *TestResult
+		if e.Test != "" {
+			key := e.Package + " " + e.Test
+			if test, ok = tests[key]; !ok {
+				test = &TestResult{Name: e.Test}
+				tests[key] = test
+				pkg.Tests = append(pkg.Tests, test)
+			}
+		}
+
+		switch e.Action {
+		case "output":
+			if test != nil {
+				test.Output += e.Output
+			}
+
+			if verbose || (test == nil && !quietOutput(e.Output)) {
+				io.WriteString(w, e.Output)
+			}
+		case "pass", "fail", "skip":
+			if test != nil {
+				test.Action = e.Action
+				test.Elapsed = e.Elapsed
+				if !verbose && e.Action == "fail" {
+					io.WriteString(w, test.Output)
+				}
+			} else {
+				pkg.Action = e.Action
+				pkg.Elapsed = e.Elapsed
+			}
+		}
+	}
+
+	list := []*PackageResult{}
+	for _, name := range order {
+		list = append(list, results[name])
+	}
+
+	return list, scanner.Err()
+}
+
+// quietOutput returns true for package output which go test prints only with -v
+func quietOutput(line string) bool {
+	return line == "PASS\n" || strings.HasPrefix(line, "=== ") || strings.HasPrefix(line, "coverage: ")
+}
+
+// coverBlock is a block of cover profile
+type coverBlock struct {
+	Stmts int
+	Count int
+}
+
+// CoverProfile is merged cover profiles
+type CoverProfile struct {
+	Mode   string
+	blocks map[string]*coverBlock // file:start,end -> block
+	order  []string
+}
+
+// ReadCoverProfile merge cover profile into p, blocks of the same position are
+// summed in count and atomic mode
+func (p *CoverProfile) ReadCoverProfile(file string) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	if p.blocks == nil {
+		p.blocks = make(map[string]*coverBlock)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "mode:") {
+			p.Mode = strings.TrimSpace(strings.TrimPrefix(line, "mode:"))
+			continue
+		}
+
+		// file:startLine.startCol,endLine.endCol numStmts count
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return fmt.Errorf("invalid cover profile %s:%s", file, line)
+		}
+
+		stmts, err1 := strconv.Atoi(fields[1])
+		count, err2 := strconv.Atoi(fields[2])
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("invalid cover profile %s:%s", file, line)
+		}
+
+		block, ok := p.blocks[fields[0]]
+		if !ok {
+			p.blocks[fields[0]] = &coverBlock{Stmts: stmts, Count: count}
+			p.order = append(p.order, fields[0])
+			continue
+		}
+
+		if p.Mode == "set" {
+			if count > block.Count {
+				block.Count = count
+			}
+		} else {
+			block.Count += count
+		}
+	}
+
+	return nil
+}
+
+// WriteFile write merged profile
+func (p *CoverProfile) WriteFile(file string) error {
+	mode := p.Mode
+	if mode == "" {
+		mode = "set"
+	}
+
+	lines := []string{"mode: " + mode}
+	for _, pos := range p.order {
+		b := p.blocks[pos]
+		lines = append(lines, fmt.Sprintf("%s %d %d", pos, b.Stmts, b.Count))
+	}
+
+	return ioutil.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+}
+
+// Coverage returns percent of covered statements of each package and total,
+// -1 if no statements
+func (p *CoverProfile) Coverage() (map[string]float64, float64) {
+	covered := make(map[string]int)
+	total := make(map[string]int)
+	allCovered, all := 0, 0
+	for pos, b := range p.blocks {
+		pkg := path.Dir(pos[:strings.LastIndex(pos, ":")])
+		total[pkg] += b.Stmts
+		all += b.Stmts
+		if b.Count > 0 {
+			covered[pkg] += b.Stmts
+			allCovered += b.Stmts
+		}
+	}
+
+	result := make(map[string]float64)
+	for pkg, n := range total {
+		result[pkg] = percent(covered[pkg], n)
+	}
+
+	return result, percent(allCovered, all)
+}
+
+func percent(n int, total int) float64 {
+	if total == 0 {
+		return -1
+	}
+
+	return float64(n) * 100 / float64(total)
+}
+
+// junit xml
+type junitSuites struct {
+	XMLName xml.Name      `xml:"testsuites"`
+	Suites  []*junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Cases    []*junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",chardata"`
+}
+
+// WriteJUnit write results as junit xml, a suite for each package
+func WriteJUnit(file string, results []*PackageResult) error {
+	doc := &junitSuites{}
+	for _, pkg := range results {
+		suite := &junitSuite{Name: pkg.Package, Time: seconds(pkg.Elapsed)}
+		tests := append([]*TestResult{}, pkg.Tests...)
+		sort.SliceStable(tests, func(i, j int) bool { return tests[i].Name < tests[j].Name })
+		for _, t := range tests {
+			c := &junitCase{ClassName: pkg.Package, Name: t.Name, Time: seconds(t.Elapsed)}
+			switch t.Action {
+			case "fail":
+				c.Failure = &junitMessage{Message: "failed", Body: t.Output}
+				suite.Failures++
+			case "skip":
+				c.Skipped = &junitMessage{Message: "skipped", Body: t.Output}
+				suite.Skipped++
+			}
+
+			suite.Tests++
+			suite.Cases = append(suite.Cases, c)
+		}
+
+		// package fails without failed test, eg: build failed
+		if pkg.Action == "fail" && suite.Failures == 0 {
+			suite.Tests++
+			suite.Failures++
+			suite.Cases = append(suite.Cases, &junitCase{ClassName: pkg.Package, Name: "package", Time: seconds(pkg.Elapsed), Failure: &junitMessage{Message: "package failed"}})
+		}
+
+		doc.Suites = append(doc.Suites, suite)
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, append([]byte(xml.Header), append(data, '\n')...), 0644)
+}
+
+func seconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', 3, 64)
+}
@@ -0,0 +1,211 @@
+package gpm
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProjectPackages(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	writeTree(t, dir, map[string]string{
+		"main.go":             "package main\n",
+		"pkg/a/a.go":          "package a\n",
+		"pkg/a/a_test.go":     "package a\n",
+		"pkg/b/b_test.go":     "package b\n",
+		"pkg/c/README.md":     "readme",
+		"pkg/a/testdata/x.go": "package x\n",
+		"vendor/v/v.go":       "package v\n",
+		"dist/d.go":           "package d\n",
+		".hidden/h.go":        "package h\n",
+		"_old/o.go":           "package o\n",
+		"tools/gen/gen.go":    "package main\n",
+		"pkg/_x.go":           "package x\n",
+	})
+
+	packages, err := ProjectPackages(dir, []string{"tools/gen/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{".", "./pkg/a", "./pkg/b"}
+	if !reflect.DeepEqual(packages, expected) {
+		t.Errorf("ProjectPackages() = %v, expected %v", packages, expected)
+	}
+}
+
+func TestReadTestEvents(t *testing.T) {
+	events := []string{
+		`{"Action":"run","Package":"app/a","Test":"TestOK"}`,
+		`{"Action":"output","Package":"app/a","Test":"TestOK","Output":"=== RUN   TestOK\n"}`,
+		`{"Action":"output","Package":"app/a","Test":"TestOK","Output":"ok output\n"}`,
+		`{"Action":"pass","Package":"app/a","Test":"TestOK","Elapsed":0.5}`,
+		`{"Action":"run","Package":"app/a","Test":"TestBad"}`,
+		`{"Action":"output","Package":"app/a","Test":"TestBad","Output":"    a_test.go:9: bad\n"}`,
+		`{"Action":"fail","Package":"app/a","Test":"TestBad","Elapsed":0.25}`,
+		`{"Action":"output","Package":"app/a","Output":"FAIL\n"}`,
+		`{"Action":"fail","Package":"app/a","Elapsed":1}`,
+		`# app/b`,
+		`b.go:3: undefined: x`,
+		`{"Action":"output","Package":"app/c","Output":"PASS\n"}`,
+		`{"Action":"skip","Package":"app/c","Test":"TestSkip"}`,
+		`{"Action":"output","Package":"app/c","Output":"coverage: 50.0% of statements\n"}`,
+		`{"Action":"pass","Package":"app/c","Elapsed":0.1}`,
+	}
+
+	cases := []struct {
+		verbose  bool
+		expected string
+	}{
+		{false, "    a_test.go:9: bad\nFAIL\n# app/b\nb.go:3: undefined: x\n"},
+		{true, "=== RUN   TestOK\nok output\n    a_test.go:9: bad\nFAIL\n# app/b\nb.go:3: undefined: x\nPASS\ncoverage: 50.0% of statements\n"},
+	}
+
+	for _, tc := range cases {
+		out := &bytes.Buffer{}
+		results, err := ReadTestEvents(strings.NewReader(strings.Join(events, "\n")), out, tc.verbose)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if out.String() != tc.expected {
+			t.Errorf("verbose %v: output %q, expected %q", tc.verbose, out.String(), tc.expected)
+		}
+
+		if len(results) != 2 || results[0].Package != "app/a" || results[1].Package != "app/c" {
+			t.Fatalf("results %+v", results)
+		}
+
+		a := results[0]
+		if a.Action != "fail" || a.Elapsed != 1 || len(a.Tests) != 2 {
+			t.Errorf("package %+v", a)
+		}
+
+		expected := &TestResult{Name: "TestBad", Action: "fail", Elapsed: 0.25, Output: "    a_test.go:9: bad\n"}
+		if !reflect.DeepEqual(a.Tests[1], expected) {
+			t.Errorf("test %+v, expected %+v", a.Tests[1], expected)
+		}
+
+		if c := results[1]; c.Action != "pass" || len(c.Tests) != 1 || c.Tests[0].Action != "skip" {
+			t.Errorf("package %+v", c)
+		}
+	}
+}
+
+func TestCoverProfile(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	writeTree(t, dir, map[string]string{
+		"a.out": "mode: count\napp/a/a.go:1.1,2.2 2 1\napp/a/a.go:3.1,4.2 3 0\napp/b/b.go:1.1,2.2 5 0\n",
+		"b.out": "mode: count\napp/a/a.go:3.1,4.2 3 2\napp/b/b.go:1.1,2.2 5 0\napp/a/a.go:1.1,2.2 2 4\n",
+		"bad":   "mode: count\napp/a/a.go:1.1,2.2 x 1\n",
+	})
+
+	p := &CoverProfile{}
+	for _, name := range []string{"a.out", "b.out"} {
+		if err := p.ReadCoverProfile(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	merged := filepath.Join(dir, "merged.out")
+	if err := p.WriteFile(merged); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "mode: count\napp/a/a.go:1.1,2.2 2 5\napp/a/a.go:3.1,4.2 3 2\napp/b/b.go:1.1,2.2 5 0\n"
+	if actual := readString(t, merged); actual != expected {
+		t.Errorf("merged %q, expected %q", actual, expected)
+	}
+
+	packages, total := p.Coverage()
+	if expected := map[string]float64{"app/a": 100, "app/b": 0}; !reflect.DeepEqual(packages, expected) || total != 50 {
+		t.Errorf("Coverage() = %v, %v, expected %v, 50", packages, total, expected)
+	}
+
+	if err := p.ReadCoverProfile(filepath.Join(dir, "bad")); err == nil {
+		t.Error("invalid profile is read")
+	}
+
+	// set mode is not summed
+	set := &CoverProfile{}
+	writeTree(t, dir, map[string]string{"set.out": "mode: set\napp/a/a.go:1.1,2.2 2 1\napp/a/a.go:1.1,2.2 2 1\n"})
+	set.ReadCoverProfile(filepath.Join(dir, "set.out"))
+	set.WriteFile(merged)
+	if actual := readString(t, merged); actual != "mode: set\napp/a/a.go:1.1,2.2 2 1\n" {
+		t.Errorf("merged set %q", actual)
+	}
+
+	if _, total := (&CoverProfile{}).Coverage(); total != -1 {
+		t.Errorf("coverage of empty profile %v, expected -1", total)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	results := []*PackageResult{
+		{Package: "app/a", Action: "fail", Elapsed: 1.5, Tests: []*TestResult{
+			{Name: "TestZ", Action: "pass", Elapsed: 0.5},
+			{Name: "TestBad", Action: "fail", Elapsed: 0.25, Output: "bad\n"},
+			{Name: "TestSkip", Action: "skip", Output: "skip\n"},
+		}},
+		{Package: "app/b", Action: "fail"},
+	}
+
+	file := filepath.Join(dir, "junit.xml")
+	if err := WriteJUnit(file, results); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(file)
+	if !bytes.HasPrefix(data, []byte(xml.Header)) {
+		t.Errorf("no xml header: %s", data)
+	}
+
+	doc := &junitSuites{}
+	if err := xml.Unmarshal(data, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Suites) != 2 {
+		t.Fatalf("suites %+v", doc.Suites)
+	}
+
+	a := doc.Suites[0]
+	if a.Name != "app/a" || a.Tests != 3 || a.Failures != 1 || a.Skipped != 1 || a.Time != "1.500" {
+		t.Errorf("suite %+v", a)
+	}
+
+	names := []string{}
+	for _, c := range a.Cases {
+		names = append(names, c.Name)
+	}
+
+	if expected := []string{"TestBad", "TestSkip", "TestZ"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("cases %v, expected %v", names, expected)
+	}
+
+	if c := a.Cases[0]; c.Failure == nil || c.Failure.Body != "bad\n" || c.Time != "0.250" || c.ClassName != "app/a" {
+		t.Errorf("failed case %+v", c)
+	}
+
+	if c := a.Cases[1]; c.Skipped == nil || c.Failure != nil {
+		t.Errorf("skipped case %+v", c)
+	}
+
+	// package failed without failed tests is a failed case
+	b := doc.Suites[1]
+	if b.Tests != 1 || b.Failures != 1 || b.Cases[0].Name != "package" {
+		t.Errorf("suite %+v", b)
+	}
+}
@@ -0,0 +1,134 @@
+package gpm
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// goVersionRe match version of go version output, eg: go1.11.13, go1.12, go1.21rc2
+var goVersionRe = regexp.MustCompile(`go(\d+)\.(\d+)(?:\.(\d+))?(?:(beta|rc)(\d+))?`)
+
+// goAndRe match constraints separated by space, which means and
+var goAndRe = regexp.MustCompile(`([0-9x*])\s+([<>=!~^])`)
+
+// NewGoConstraint parse go version constraint, space is the same as comma, eg: >=1.10 <1.12
+func NewGoConstraint(constraint string) (*semver.Constraints, error) {
+	return semver.NewConstraint(goAndRe.ReplaceAllString(strings.TrimSpace(constraint), "$1, $2"))
+}
+
+// ParseGoVersion convert go version to semver, eg: go1.12 -> 1.12.0, go1.21rc2 -> 1.21.0-rc.2
+func ParseGoVersion(version string) (*semver.Version, error) {
+	m := goVersionRe.FindStringSubmatch(version)
+	if m == nil {
+		return nil, fmt.Errorf("unknown go version %s", version)
+	}
+
+	patch := m[3]
+	if patch == "" {
+		patch = "0"
+	}
+
+	v := m[1] + "." + m[2] + "." + patch
+	if m[4] != "" {
+		v += "-" + m[4] + "." + m[5]
+	}
+
+	return semver.NewVersion(v)
+}
+
+// goVersionOf returns version of go binary, GOROOT is root if not empty
+func goVersionOf(bin string, root string) (*semver.Version, error) {
+	cmd := exec.Command(bin, "version")
+	if root != "" {
+		cmd.Env = MergeEnv(os.Environ(), []string{"GOROOT=" + root})
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseGoVersion(strings.TrimSpace(string(out)))
+}
+
+// GoRoots returns installed go sdks of settings, globs are expanded
+func (ctx *Ctx) GoRoots() []string {
+	roots := []string{}
+	for _, pattern := range ctx.Settings.GoRoots {
+		matches, err := filepath.Glob(ExpandHome(pattern))
+		if err != nil {
+			continue
+		}
+
+		for _, root := range matches {
+			if Exists(goBin(root)) {
+				roots = append(roots, root)
+			}
+		}
+	}
+
+	return roots
+}
+
+func goBin(root string) string {
+	name := "go"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+
+	return filepath.Join(root, "bin", name)
+}
+
+// SelectGo check go in PATH against go constraint of gpm.yaml, the newest
+// matched sdk of go-roots is used if it does not match, by PATH and GOROOT
+func (ctx *Ctx) SelectGo() error {
+	if ctx.Go == "" {
+		return nil
+	}
+
+	c, err := NewGoConstraint(ctx.Go)
+	if err != nil {
+		return fmt.Errorf("invalid go constraint %s:%+v", ctx.Go, err)
+	}
+
+	current := "not found"
+	if bin, err := exec.LookPath("go"); err == nil {
+		v, err := goVersionOf(bin, "")
+		if err == nil && c.Check(v) {
+			return nil
+		}
+
+		if v != nil {
+			current = "go" + v.String()
+		}
+	}
+
+	var found *semver.Version
+	root := ""
+	for _, r := range ctx.GoRoots() {
+		v, err := goVersionOf(goBin(r), r)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+
+		if found == nil || v.GreaterThan(found) {
+			found, root = v, r
+		}
+	}
+
+	if found == nil {
+		return fmt.Errorf("go %s is required by %s, but go in PATH is %s and no sdk of go-roots matches, install one and add it by: gpm config set go-roots ~/sdk/go1.x.y", ctx.Go, ConfName, current)
+	}
+
+	ctx.Info("--> Use go%s of %s", found, root)
+	os.Setenv("GOROOT", root)
+	os.Setenv("PATH", filepath.Join(root, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return nil
+}
@@ -0,0 +1,156 @@
+package gpm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestParseGoVersion(t *testing.T) {
+	cases := map[string]string{
+		"go version go1.12 linux/amd64":      "1.12.0",
+		"go version go1.11.13 darwin/amd64":  "1.11.13",
+		"go version go1.21rc2 linux/arm64":   "1.21.0-rc.2",
+		"go1.13beta1":                        "1.13.0-beta.1",
+		"go version devel +b7a85e0003 linux": "",
+		"1.12":                               "",
+	}
+
+	for version, expected := range cases {
+		v, err := ParseGoVersion(version)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("ParseGoVersion(%s) = %s, expected error", version, v)
+			}
+			continue
+		}
+
+		if err != nil || v.String() != expected {
+			t.Errorf("ParseGoVersion(%s) = %v, %v, expected %s", version, v, err, expected)
+		}
+	}
+}
+
+func TestNewGoConstraint(t *testing.T) {
+	cases := []struct {
+		constraint string
+		version    string
+		expected   bool
+	}{
+		{">=1.10 <1.12", "go1.11.13", true},
+		{">=1.10 <1.12", "go1.12", false},
+		{">=1.10, <1.12", "go1.10", true},
+		{" ~1.12 ", "go1.12.17", true},
+		{"1.12.x", "go1.13", false},
+		{">=1.11 !=1.11.2", "go1.11.2", false},
+		{">=1.21", "go1.21rc2", false},
+		{">=1.21.0-rc.1", "go1.21rc2", true},
+		{">=1.10 || 1.8.x", "go1.8.7", true},
+	}
+
+	for _, tc := range cases {
+		c, err := NewGoConstraint(tc.constraint)
+		if err != nil {
+			t.Errorf("NewGoConstraint(%s) fail:%+v", tc.constraint, err)
+			continue
+		}
+
+		v, _ := ParseGoVersion(tc.version)
+		if actual := c.Check(v); actual != tc.expected {
+			t.Errorf("%s check %s = %v, expected %v", tc.constraint, tc.version, actual, tc.expected)
+		}
+	}
+
+	for _, constraint := range []string{"go1.12", ">=1.x.y.z", ">= abc"} {
+		if _, err := NewGoConstraint(constraint); err == nil {
+			t.Errorf("NewGoConstraint(%s) is valid", constraint)
+		}
+	}
+}
+
+// fakeGo write a go script which prints version
+func fakeGo(t *testing.T, root string, version string) {
+	bin := goBin(root)
+	os.MkdirAll(filepath.Dir(bin), 0755)
+	script := "#!/bin/sh\necho go version " + version + " " + runtime.GOOS + "/" + runtime.GOARCH + "\n"
+	if err := ioutil.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectGo(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("go is faked by shell scripts")
+	}
+
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	for _, key := range []string{"PATH", "GOROOT"} {
+		old, ok := os.LookupEnv(key)
+		if ok {
+			defer os.Setenv(key, old)
+		} else {
+			defer os.Unsetenv(key)
+		}
+	}
+
+	fakeGo(t, filepath.Join(dir, "path"), "go1.10.8")
+	fakeGo(t, filepath.Join(dir, "sdk", "go1.11.2"), "go1.11.2")
+	fakeGo(t, filepath.Join(dir, "sdk", "go1.11.13"), "go1.11.13")
+	fakeGo(t, filepath.Join(dir, "sdk", "go1.12.5"), "go1.12.5")
+	os.MkdirAll(filepath.Join(dir, "sdk", "gonone"), 0755)
+
+	settings := DefaultSettings()
+	settings.GoRoots = []string{filepath.Join(dir, "sdk", "go*"), filepath.Join(dir, "missing")}
+	ctx := &Ctx{Logger: NewLogger(), Config: &Config{}, Settings: settings}
+	if roots := ctx.GoRoots(); len(roots) != 3 {
+		t.Errorf("GoRoots() = %v, expected 3 sdks", roots)
+	}
+
+	cases := []struct {
+		constraint string
+		root       string
+		invalid    bool
+	}{
+		{"", "", false},
+		{">=1.10 <1.11", "", false},
+		{">=1.11 <1.12", "go1.11.13", false},
+		{"~1.11.1 !=1.11.13", "go1.11.2", false},
+		{">=1.11", "go1.12.5", false},
+		{">=1.13", "", true},
+		{"go1.12", "", true},
+	}
+
+	path := filepath.Join(dir, "path", "bin")
+	for _, tc := range cases {
+		os.Setenv("PATH", path)
+		os.Unsetenv("GOROOT")
+		ctx.Go = tc.constraint
+		err := ctx.SelectGo()
+		if (err != nil) != tc.invalid {
+			t.Errorf("SelectGo(%s) error %v, expected invalid %v", tc.constraint, err, tc.invalid)
+			continue
+		}
+
+		root := ""
+		if tc.root != "" {
+			root = filepath.Join(dir, "sdk", tc.root)
+		}
+
+		if actual := os.Getenv("GOROOT"); actual != root {
+			t.Errorf("SelectGo(%s) GOROOT = %s, expected %s", tc.constraint, actual, root)
+		}
+
+		expected := path
+		if root != "" {
+			expected = filepath.Join(root, "bin") + string(os.PathListSeparator) + path
+		}
+
+		if actual := os.Getenv("PATH"); actual != expected {
+			t.Errorf("SelectGo(%s) PATH = %s, expected %s", tc.constraint, actual, expected)
+		}
+	}
+}
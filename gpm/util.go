@@ -2,7 +2,12 @@ package gpm
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"os/exec"
@@ -22,6 +27,126 @@ func Exists(path string) bool {
 	return false
 }
 
+// IsSymlink check path is a symbolic link
+func IsSymlink(path string) bool {
+	fi, err := os.Lstat(path)
+	return err == nil && fi.Mode()&os.ModeSymlink != 0
+}
+
+// ExpandHome replace leading ~ with home directory
+func ExpandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := Home()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, path[1:])
+}
+
+// CopyDir copy directory recursively, .git etc. vcs dirs are skipped
+func CopyDir(src string, dst string) error {
+	return filepath.Walk(src, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, rel)
+		if fi.IsDir() {
+			switch fi.Name() {
+			case ".git", ".hg", ".bzr", ".svn":
+				return filepath.SkipDir
+			}
+
+			return os.MkdirAll(target, 0755)
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		return CopyFile(path, target, fi.Mode())
+	})
+}
+
+// TempDir create a temporary directory
+func TempDir(name string) (string, error) {
+	return ioutil.TempDir("", "gpm-"+name+"-")
+}
+
+// HashDir returns sha256 of all files in dir, vcs dirs are skipped.
+// each file is hashed alone, then length prefixed path and hash of files in order
+func HashDir(dir string) (string, error) {
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if fi.IsDir() {
+			switch fi.Name() {
+			case ".git", ".hg", ".bzr", ".svn":
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		sum, err := HashFile(path)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+		fmt.Fprintf(h, "%d:%s%s", len(rel), rel, sum)
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// CopyFile copy a single file
+func CopyFile(src string, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
 // Home returns the home directory for the executing user.
 //
 // This uses an OS-specific method for discovering the home directory.
@@ -75,6 +200,21 @@ var scpSyntaxRe = regexp.MustCompile(`^([a-zA-Z0-9_]+)@([a-zA-Z0-9._-]+):(.*)$`)
 
 // CacheLocal return local path for cache
 func CacheLocal(repo string) (string, error) {
+	key, err := CacheKey(repo)
+	if err != nil {
+		return "", err
+	}
+
+	home, err := Home()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".gpm", key), nil
+}
+
+// CacheKey return the dir name of repo in cache
+func CacheKey(repo string) (string, error) {
 	var u *url.URL
 	var err error
 	var strip bool
@@ -114,12 +254,7 @@ func CacheLocal(repo string) (string, error) {
 
 	key = strings.Replace(key, ":", "-", -1)
 
-	home, err := Home()
-	if err != nil {
-		return "", err
-	}
-
-	return filepath.Join(home, ".gpm", key), nil
+	return key, nil
 }
 
 // ParseRepo repo = url@version
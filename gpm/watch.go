@@ -0,0 +1,108 @@
+package gpm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Watcher poll .go files of project (vendor excluded) and gpm.yaml for changes
+type Watcher struct {
+	Root     string
+	Ignore   []string      // files not watched, relative to root, eg: generated stamp file
+	Interval time.Duration // poll interval
+	Debounce time.Duration // changes are collected until files are quiet for this time
+	files    map[string]string
+}
+
+// NewWatcher create watcher and record current files
+func NewWatcher(root string, ignore []string, interval time.Duration, debounce time.Duration) *Watcher {
+	w := &Watcher{Root: root, Ignore: ignore, Interval: interval, Debounce: debounce}
+	w.files, _ = w.scan()
+	return w
+}
+
+// scan returns path -> modify time and size of watched files
+func (w *Watcher) scan() (map[string]string, error) {
+	skip := make(map[string]bool)
+	for _, file := range w.Ignore {
+		skip[filepath.ToSlash(filepath.Clean(file))] = true
+	}
+
+	files := make(map[string]string)
+	err := filepath.Walk(w.Root, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			// removed while walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		name := fi.Name()
+		if fi.IsDir() {
+			if path != w.Root && (name == "vendor" || name == DistDir || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")) {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		rel, err := filepath.Rel(w.Root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if skip[rel] || (rel != ConfName && !strings.HasSuffix(name, ".go")) {
+			return nil
+		}
+
+		files[rel] = fmt.Sprintf("%d %d", fi.ModTime().UnixNano(), fi.Size())
+		return nil
+	})
+
+	return files, err
+}
+
+// changes returns changed files since last scan, and record them
+func (w *Watcher) changes() []string {
+	files, err := w.scan()
+	if err != nil {
+		return nil
+	}
+
+	changed := diffKeys(w.files, files)
+	w.files = files
+	return changed
+}
+
+// Wait block until files are changed, returns sorted changed files after debounce
+func (w *Watcher) Wait() []string {
+	changed := []string{}
+	for len(changed) == 0 {
+		time.Sleep(w.Interval)
+		changed = w.changes()
+	}
+
+	// editors write many files, wait them quiet
+	for {
+		time.Sleep(w.Debounce)
+		more := w.changes()
+		if len(more) == 0 {
+			break
+		}
+
+		for _, file := range more {
+			if !hasString(changed, file) {
+				changed = append(changed, file)
+			}
+		}
+	}
+
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,76 @@
+package gpm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatcherChanges(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	writeTree(t, dir, map[string]string{
+		ConfName:          "package: app\n",
+		"main.go":         "package main\n",
+		"version.go":      "package main\n",
+		"pkg/a/a.go":      "package a\n",
+		"README.md":       "readme",
+		"vendor/b/b.go":   "package b\n",
+		"pkg/testdata/x":  "x",
+		".git/HEAD":       "ref",
+		"dist/app/app.go": "package main\n",
+	})
+
+	w := NewWatcher(dir, []string{"./version.go"}, time.Millisecond, time.Millisecond)
+	if changed := w.changes(); len(changed) != 0 {
+		t.Errorf("changes without edit: %v", changed)
+	}
+
+	// edits change size, modify time may be the same on coarse file systems
+	cases := []struct {
+		files    map[string]string
+		remove   []string
+		expected []string
+	}{
+		{map[string]string{"main.go": "package main\n\n"}, nil, []string{"main.go"}},
+		{map[string]string{ConfName: "package: app\nimport: []\n", "pkg/a/b.go": "package a\n"}, nil, []string{ConfName, "pkg/a/b.go"}},
+		{nil, []string{"pkg/a/a.go"}, []string{"pkg/a/a.go"}},
+		{map[string]string{"README.md": "readme2", "version.go": "package main\n\n", "vendor/b/b.go": "package b\n\n", "dist/app/app.go": "package main\n\n"}, nil, []string{}},
+	}
+
+	for i, tc := range cases {
+		writeTree(t, dir, tc.files)
+		for _, file := range tc.remove {
+			os.Remove(filepath.Join(dir, file))
+		}
+
+		if changed := w.changes(); !reflect.DeepEqual(changed, tc.expected) {
+			t.Errorf("case %d: changes() = %v, expected %v", i, changed, tc.expected)
+		}
+	}
+}
+
+func TestWatcherWait(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	writeTree(t, dir, map[string]string{"main.go": "package main\n"})
+	w := NewWatcher(dir, nil, 10*time.Millisecond, 100*time.Millisecond)
+
+	// changes in debounce are collected together
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\n"), 0644)
+		time.Sleep(30 * time.Millisecond)
+		ioutil.WriteFile(filepath.Join(dir, "b.go"), []byte("package main\n"), 0644)
+	}()
+
+	expected := []string{"b.go", "main.go"}
+	if changed := w.Wait(); !reflect.DeepEqual(changed, expected) {
+		t.Errorf("Wait() = %v, expected %v", changed, expected)
+	}
+}